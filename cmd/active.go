@@ -2,19 +2,34 @@ package cmd
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/Naman1997/discovr/internal"
+	"github.com/Naman1997/discovr/verbose"
 	"github.com/spf13/cobra"
 )
 
 var (
-	ExportPathActive string
-	networkInterface string
-	targetCIDR       string
-	ICMPMode         bool
-	concurrency      int
-	timeout          int
-	count            int
+	ExportPathActive    string
+	networkInterface    string
+	targetCIDR          string
+	excludeActive       string
+	excludeFileActive   string
+	ICMPMode            bool
+	concurrency         int
+	timeout             int
+	count               int
+	DNSTablePath        string
+	vlanID              int
+	IPv6Mode            bool
+	AllInterfacesActive bool
+	rate                int
+	retries             int
+	arpWait             int
+	adaptive            bool
+	tcpPing             bool
+	tcpPorts            []int
+	udpPing             bool
 )
 
 var activeCmd = &cobra.Command{
@@ -22,8 +37,88 @@ var activeCmd = &cobra.Command{
 	Short: "Scan network actively",
 	Long:  `Sends network requests across the CIDR range to determine device ip, mac address and other details with arp requests or icmp requests.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		internal.DefaultScan(networkInterface, targetCIDR, ICMPMode, concurrency, timeout, count)
-		if !ICMPMode {
+		if DNSTablePath != "" {
+			if err := internal.LoadPassiveDNS(DNSTablePath); err != nil {
+				verbose.VerboseFatalfMsg("Unable to load passive DNS table: %v", err)
+			}
+		}
+		if cmd.Flags().Changed("tcp-ports") {
+			tcpPing = true
+		}
+		pingMode := ICMPMode || tcpPing || udpPing
+		if vlanID != 0 && (pingMode || IPv6Mode) {
+			verbose.VerboseFatalfMsg("--vlan only applies to ARP scans")
+		}
+		if pingMode && IPv6Mode {
+			verbose.VerboseFatalfMsg("--ipv6 cannot be combined with --mode, --tcp or --udp")
+		}
+		for _, port := range tcpPorts {
+			if port < 1 || port > 65535 {
+				verbose.VerboseFatalfMsg("invalid TCP port %d", port)
+			}
+		}
+		if retries < 0 || arpWait < 0 {
+			verbose.VerboseFatalfMsg("--retries and --wait cannot be negative")
+		}
+		interfaces := []string{networkInterface}
+		switch {
+		case AllInterfacesActive && networkInterface != "":
+			verbose.VerboseFatalfMsg("--all-interfaces cannot be combined with --interface")
+		case AllInterfacesActive && pingMode:
+			verbose.VerboseFatalfMsg("--all-interfaces only applies to ARP and IPv6 scans")
+		case AllInterfacesActive:
+			var err error
+			if interfaces, err = internal.ActiveInterfaces(); err != nil {
+				verbose.VerboseFatalfMsg("%v", err)
+			}
+			verbose.VerbosePrintf("Scanning all interfaces: %s\n", strings.Join(interfaces, ", "))
+		case networkInterface == "":
+			verbose.VerboseFatalfMsg("required flag \"interface\" not set (or use --all-interfaces)")
+		}
+		if vlanID < 0 || vlanID > 4094 {
+			verbose.VerboseFatalfMsg("invalid VLAN ID %d (1-4094)", vlanID)
+		}
+		var targets, exclude *internal.TargetSet
+		var hostnames []string
+		if DryRun {
+			fmt.Println("Dry run, nothing is sent.")
+			targets, exclude, hostnames = parseDryRunTargets(targetCIDR, excludeActive, excludeFileActive)
+		} else {
+			targets, exclude = parseTargetFlags(targetCIDR, excludeActive, excludeFileActive)
+		}
+		opts := internal.ActiveOptions{
+			Targets:     targets,
+			Exclude:     exclude,
+			ICMPMode:    ICMPMode,
+			IPv6Mode:    IPv6Mode,
+			Concurrency: concurrency,
+			TimeoutSec:  timeout,
+			Count:       count,
+			VLAN:        vlanID,
+			Rate:        rate,
+			Retries:     retries,
+			WaitSec:     arpWait,
+			Adaptive:    adaptive,
+			TCPPing:     tcpPing,
+			TCPPorts:    tcpPorts,
+			UDPPing:     udpPing,
+		}
+		if DryRun {
+			if err := internal.PrintActivePlan(interfaces, opts, hostnames); err != nil {
+				verbose.VerboseFatalfMsg("%v", err)
+			}
+			if scanTargets, err := internal.ScanTargets(interfaces, opts); err == nil {
+				printScopeVerdict(scanTargets, hostnames)
+			}
+			return
+		}
+		scanTargets, err := internal.ScanTargets(interfaces, opts)
+		if err != nil {
+			verbose.VerboseFatalfMsg("%v", err)
+		}
+		opts.Scope = guardScan(scanTargets)
+		internal.DefaultScan(interfaces, opts)
+		if !pingMode {
 			internal.ShowResults(internal.Defaultscan_results)
 			internal.ExportCSV(ExportPathActive, internal.Defaultscan_results)
 			internal.UploadResults(UploadUrl, ExportPathActive, internal.Defaultscan_results, "active_")
@@ -38,15 +133,26 @@ var activeCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(activeCmd)
 	activeCmd.Flags().BoolVarP(&ICMPMode, "mode", "m", false, "Use ICMP echo requests instead of ARP (true/false) (default false)")
+	activeCmd.Flags().BoolVar(&tcpPing, "tcp", false, "Find hosts that drop ICMP by connecting to --tcp-ports (a SYN-ACK or RST counts as alive)")
+	activeCmd.Flags().IntSliceVar(&tcpPorts, "tcp-ports", internal.DefaultTCPPingPorts, "TCP ports probed by --tcp (implies --tcp)")
+	activeCmd.Flags().BoolVar(&udpPing, "udp", false, "Find hosts with DNS, NTP and SNMP probes (a reply or ICMP port unreachable counts as alive)")
 	activeCmd.Flags().StringVarP(&networkInterface, "interface", "i", "", "Network interface to use for scanning (ARP)")
-	activeCmd.Flags().StringVarP(&targetCIDR, "cidr", "r", "", "Target CIDR to scan (ARP, ICMP)")
+	activeCmd.Flags().StringVarP(&targetCIDR, "cidr", "r", "", "Targets to scan: IPs, CIDRs, ranges (10.0.0.5-10.0.0.40), hostnames or @file, comma separated")
+	activeCmd.Flags().StringVar(&excludeActive, "exclude", "", "Targets never to probe, in the format of --cidr")
+	activeCmd.Flags().StringVar(&excludeFileActive, "exclude-file", "", "File listing targets never to probe")
 	activeCmd.Flags().StringVarP(&ExportPathActive, "export", "e", "", "Export results to CSV file")
-	activeCmd.Flags().IntVarP(&concurrency, "concurrency", "p", 50, "Number of concurrent workers (ICMP)")
-	activeCmd.Flags().IntVarP(&timeout, "timeout", "t", 2, "Timeout in seconds to wait for each reply (ICMP)")
+	activeCmd.Flags().IntVarP(&concurrency, "concurrency", "p", 50, "Number of concurrent workers (ICMP, TCP, UDP)")
+	activeCmd.Flags().IntVar(&rate, "rate", 1000, "Maximum ARP requests per second across all interfaces (0 is unlimited)")
+	activeCmd.Flags().IntVar(&retries, "retries", 0, "Extra ARP rounds sent to hosts that have not replied yet")
+	activeCmd.Flags().IntVar(&arpWait, "wait", 3, "Seconds to wait for ARP replies after each round")
+	activeCmd.Flags().BoolVar(&adaptive, "adaptive", false, "Double the wait every ARP round, end rounds once replies stop and stop retrying when a round finds nothing new")
+	activeCmd.Flags().IntVarP(&timeout, "timeout", "t", 2, "Timeout in seconds to wait for each reply (ICMP, TCP, UDP)")
 	activeCmd.Flags().IntVarP(&count, "count", "c", 1, "Number of requests to send to each IP (ICMP)")
-	err := activeCmd.MarkFlagRequired("interface")
-	if err != nil {
-		fmt.Println("Error marking flag as required:", err)
-	}
+	activeCmd.Flags().BoolVarP(&IPv6Mode, "ipv6", "6", false, "Discover IPv6 hosts with multicast echo and neighbor discovery instead of ARP")
+	activeCmd.Flags().IntVar(&vlanID, "vlan", 0, "Send 802.1Q tagged ARP requests for this VLAN ID on a trunk interface (ARP, needs --cidr)")
+	activeCmd.Flags().StringVar(&DNSTablePath, "dns-table", "", "Passive DNS table (passive --export ..._dns.csv) used to name hosts without PTR records")
+	addScopeFlags(activeCmd)
+	activeCmd.Flags().BoolVar(&DryRun, "dry-run", false, "Print the interfaces, source addresses, expanded targets, rate and estimated duration without sending anything")
+	activeCmd.Flags().BoolVar(&AllInterfacesActive, "all-interfaces", false, "Sweep every subnet of every interface that is up, including secondary addresses (ARP, IPv6)")
 
 }
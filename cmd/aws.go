@@ -21,6 +21,10 @@ var awsCmd = &cobra.Command{
 	Long: `Scan your AWS environment for EC2 instances
 `,
 	Run: func(cmd *cobra.Command, args []string) {
+		if DryRun {
+			internal.PrintAwsPlan(Region, Config, Credential, Profile)
+			return
+		}
 		internal.AwsScan(Region, Config, Credential, Profile)
 		internal.ShowResults(internal.Aws_results)
 		internal.ExportCSV(AwsCsvExportPath, internal.Aws_results)
@@ -40,4 +44,5 @@ func init() {
 	default:
 		awsCmd.Flags().StringSliceVarP(&Config, "config", "c", []string{}, "Custom AWS config file(s)")
 	}
+	awsCmd.Flags().BoolVar(&DryRun, "dry-run", false, "Print the regions and API calls a scan would make without calling them")
 }
@@ -19,6 +19,10 @@ Usage:
 discovr azure --config FILENAME
 `,
 	Run: func(cmd *cobra.Command, args []string) {
+		if DryRun {
+			internal.PrintAzurePlan(SubscriptionID)
+			return
+		}
 		internal.Azurescan(SubscriptionID)
 		internal.ShowResults(internal.Azure_results)
 		internal.ExportCSV(AzureCsvExportPath, internal.Azure_results)
@@ -30,4 +34,5 @@ func init() {
 	rootCmd.AddCommand(azureCmd)
 	azureCmd.Flags().StringVarP(&SubscriptionID, "SubID", "s", "default", "Subscription ID for creating clients for API calls")
 	azureCmd.Flags().StringVarP(&AzureCsvExportPath, "export", "e", "", "Export results to CSV file")
+	azureCmd.Flags().BoolVar(&DryRun, "dry-run", false, "Print the subscription and API calls a scan would make without calling them")
 }
@@ -17,6 +17,10 @@ var gcpCmd = &cobra.Command{
 	Long: `Scan your GCP environment for Virtual machines
 `,
 	Run: func(cmd *cobra.Command, args []string) {
+		if DryRun {
+			internal.PrintGcpPlan(CredFile, ProjectFilterStr)
+			return
+		}
 		internal.GcpScan(CredFile, ProjectFilterStr)
 		internal.ShowResults(internal.Gcp_results)
 		internal.ExportCSV(GcpCsvExportPath, internal.Gcp_results)
@@ -29,4 +33,5 @@ func init() {
 	gcpCmd.Flags().StringVarP(&ProjectFilterStr, "project", "p", "", "Comma separated project names to use as a filter")
 	gcpCmd.Flags().StringVarP(&CredFile, "cred", "c", "", "Path to service account json file to use for auth")
 	gcpCmd.Flags().StringVarP(&GcpCsvExportPath, "export", "e", "", "Export results to CSV file")
+	gcpCmd.Flags().BoolVar(&DryRun, "dry-run", false, "Print the projects and API calls a scan would make without calling them")
 }
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"slices"
+
+	"github.com/Naman1997/discovr/internal"
+	"github.com/Naman1997/discovr/verbose"
+	"github.com/spf13/cobra"
+)
+
+var (
+	ImportExportPath string
+	ImportDiff       bool
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import <file>... | --diff <old> <new>",
+	Short: "Import results from previous scan exports",
+	Long: `Reads CSV or JSON files exported by any discovr command, or XML reports written by a standalone nmap run (nmap -oX).
+The scan type is detected from the column names. Results from several files of the same type are merged;
+rows are appended as they are. With --diff, two exports of the same scan type are compared instead, and the
+rows added and removed between them are shown and exported with _added and _removed suffixes.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if ImportDiff {
+			if len(args) != 2 {
+				verbose.VerboseFatalfMsg("--diff compares exactly two files, got %d", len(args))
+			}
+			if UploadUrl != "" {
+				verbose.VerboseFatalfMsg("--diff results are not uploaded, drop --url")
+			}
+			if err := internal.DiffImports(args[0], args[1], ImportExportPath); err != nil {
+				verbose.VerboseFatalfMsg("%v", err)
+			}
+			return
+		}
+
+		var kinds []internal.ImportKind
+		for _, path := range args {
+			kind, err := internal.ImportFile(path)
+			if err != nil {
+				verbose.VerboseFatalfMsg("Import of %s failed: %v", path, err)
+			}
+			if !slices.Contains(kinds, kind) {
+				kinds = append(kinds, kind)
+			}
+		}
+
+		for _, kind := range kinds {
+			// Each kind gets its own export file, so an upload sends the file of its own kind
+			path := ImportExportPath
+			if len(kinds) > 1 {
+				path = internal.ExportPathWithSuffix(ImportExportPath, "_"+string(kind))
+			}
+			switch kind {
+			case internal.ImportArp:
+				reportImport(internal.Defaultscan_results, path, "active_")
+			case internal.ImportIcmp:
+				reportImport(internal.Icmpscan_results, path, "active_")
+			case internal.ImportPassive:
+				reportImport(internal.Passive_results, path, "passive_")
+			case internal.ImportHosts:
+				reportImport(internal.PassiveHost_results, path, "passive_hosts_")
+			case internal.ImportFlows:
+				reportImport(internal.PassiveFlow_results, path, "passive_flows_")
+			case internal.ImportNeighbours:
+				reportImport(internal.Neighbour_results, path, "passive_neighbours_")
+			case internal.ImportPassiveDNS:
+				reportImport(internal.PassiveDNS_results, path, "passive_dns_")
+			case internal.ImportNmap:
+				reportImport(internal.Active_results, path, "nmap_")
+			case internal.ImportAws:
+				reportImport(internal.Aws_results, path, "aws_")
+			case internal.ImportAzure:
+				reportImport(internal.Azure_results, path, "azure_")
+			case internal.ImportGcp:
+				reportImport(internal.Gcp_results, path, "gcp_")
+			}
+		}
+	},
+}
+
+// reportImport shows, exports and uploads the imported results of one kind.
+func reportImport[T any](data []T, path string, prefix string) {
+	internal.ShowResults(data)
+	internal.ExportCSV(path, data)
+	internal.UploadResults(UploadUrl, path, data, prefix)
+}
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+	importCmd.Flags().StringVarP(&ImportExportPath, "export", "e", "", "Export merged results to CSV file, one file per scan type with a _<type> suffix when the files hold several")
+	importCmd.Flags().BoolVar(&ImportDiff, "diff", false, "Compare two exports of the same scan type and show the rows added and removed")
+}
@@ -23,6 +23,7 @@ var (
 	regionselect    string
 	netInterface    string
 	tCIDR           string
+	excludeTargets  string
 	subID           string
 	icmpmode        bool
 	projectfilter   string
@@ -63,8 +64,14 @@ func RunTui() {
 					Options(options...).
 					Value(&netInterface),
 				huh.NewInput().
-					Title("Enter a CIDR range:").
-					Value(&tCIDR),
+					Title("Enter targets:").
+					Placeholder("default = interface network | 10.0.0.0/24, 10.0.1.5-10.0.1.40, host.lan, @targets.txt").
+					Value(&tCIDR).
+					Validate(internal.ValidateTargets),
+				huh.NewInput().
+					Title("Enter targets to exclude:").
+					Value(&excludeTargets).
+					Validate(internal.ValidateTargets),
 				huh.NewConfirm().
 					Title("ICMP Scan type:").
 					Value(&icmpmode),
@@ -82,7 +89,25 @@ func RunTui() {
 		)
 		Runform(form)
 		VerboseEnabled()
-		internal.DefaultScan(netInterface, tCIDR, icmpmode, concurrency, timeout, count)
+		targets, exclude := parseTargetFlags(tCIDR, excludeTargets, "")
+		opts := internal.ActiveOptions{
+			Targets:     targets,
+			Exclude:     exclude,
+			ICMPMode:    icmpmode,
+			Concurrency: concurrency,
+			TimeoutSec:  timeout,
+			Count:       count,
+			Rate:        rate,
+			Retries:     retries,
+			WaitSec:     arpWait,
+			Adaptive:    adaptive,
+		}
+		scanTargets, err := internal.ScanTargets([]string{netInterface}, opts)
+		if err != nil {
+			verbose.VerboseFatalfMsg("%v", err)
+		}
+		opts.Scope = guardScan(scanTargets)
+		internal.DefaultScan([]string{netInterface}, opts)
 		if !icmpmode {
 			internal.ShowResults(internal.Defaultscan_results)
 			internal.ExportCSV(exportpath, internal.Defaultscan_results)
@@ -149,7 +174,7 @@ func RunTui() {
 		} else {
 			duration, _ = strconv.Atoi(durationStr)
 		}
-		internal.PassiveScan(selectinterface, duration)
+		internal.PassiveScan([]string{selectinterface}, duration, internal.PassiveOptions{})
 		internal.ShowResults(internal.Passive_results)
 		internal.ExportCSV(exportpath, internal.Passive_results)
 		internal.UploadResults(UploadUrl, exportpath, internal.Passive_results, "passive_")
@@ -159,10 +184,14 @@ func RunTui() {
 		form := huh.NewForm(
 			huh.NewGroup(
 				huh.NewInput().
-					Title("Enter Target IP:").
-					Placeholder("default = 127.0.0.1").
+					Title("Enter targets:").
+					Placeholder("default = 127.0.0.1 | 10.0.0.0/24, 10.0.1.5-10.0.1.40, host.lan, @targets.txt").
 					Value(&ip).
-					Validate(validateIP),
+					Validate(internal.ValidateTargets),
+				huh.NewInput().
+					Title("Enter targets to exclude:").
+					Value(&excludeTargets).
+					Validate(internal.ValidateTargets),
 				huh.NewInput().
 					Title("Enter Ports").
 					Placeholder("default = Top 1000 ports | specify ports eg: 80,445").
@@ -195,7 +224,12 @@ func RunTui() {
 		if ip == "" {
 			ip = "127.0.0.1"
 		}
-		internal.NmapScan(ip, ports, osdet)
+		targets, exclude := parseTargetFlags(ip, excludeTargets, "")
+		if targets = targets.Subtract(exclude); targets.Empty() {
+			verbose.VerboseFatalfMsg("no targets left to scan")
+		}
+		guardScan(targets)
+		internal.NmapScan(targets.CIDRs(), ports, osdet)
 		internal.ShowResults(internal.Active_results)
 		internal.ExportCSV(exportpath, internal.Active_results)
 		internal.UploadResults(UploadUrl, exportpath, internal.Active_results, "nmap_")
@@ -1,7 +1,11 @@
 package cmd
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/Naman1997/discovr/internal"
+	"github.com/Naman1997/discovr/verbose"
 	"github.com/spf13/cobra"
 )
 
@@ -10,6 +14,8 @@ var (
 	Ports       string
 	OsDetection bool
 	PathActive  string
+	ExcludeNmap string
+	ExcludeFile string
 )
 
 var nmapCmd = &cobra.Command{
@@ -17,7 +23,38 @@ var nmapCmd = &cobra.Command{
 	Short: "Scan network with nmap",
 	Long:  `Sends network requests with NMAP tool across the CIDR range to determine device ip, mac address and other details`,
 	Run: func(cmd *cobra.Command, args []string) {
-		internal.NmapScan(Target, Ports, OsDetection)
+		var targets, exclude *internal.TargetSet
+		var hostnames []string
+		if DryRun {
+			fmt.Println("Dry run, nothing is sent.")
+			targets, exclude, hostnames = parseDryRunTargets(Target, ExcludeNmap, ExcludeFile)
+		} else {
+			targets, exclude = parseTargetFlags(Target, ExcludeNmap, ExcludeFile)
+		}
+		targets = targets.Subtract(exclude)
+		if targets.Empty() && len(hostnames) == 0 {
+			verbose.VerboseFatalfMsg("no targets left to scan")
+		}
+		if DryRun {
+			// Hostnames stand in for the addresses they resolve to
+			commandLine, err := internal.NmapCommandLine(append(targets.CIDRs(), hostnames...), Ports, OsDetection)
+			if err != nil {
+				verbose.VerboseFatalfMsg("%v", err)
+			}
+			switch {
+			case len(hostnames) == 0:
+				fmt.Printf("Targets:      %d addresses, %v\n", targets.Count(), targets)
+			case targets.Empty():
+				fmt.Printf("Targets:      %s (resolved by nmap)\n", strings.Join(hostnames, ", "))
+			default:
+				fmt.Printf("Targets:      %d addresses, %v, plus %s (resolved by nmap)\n", targets.Count(), targets, strings.Join(hostnames, ", "))
+			}
+			fmt.Printf("Command:      %s\n", commandLine)
+			printScopeVerdict(targets, hostnames)
+			return
+		}
+		guardScan(targets)
+		internal.NmapScan(targets.CIDRs(), Ports, OsDetection)
 		internal.ShowResults(internal.Active_results)
 		internal.ExportCSV(PathActive, internal.Active_results)
 		internal.UploadResults(UploadUrl, PathActive, internal.Active_results, "nmap_")
@@ -26,8 +63,12 @@ var nmapCmd = &cobra.Command{
 
 func init() {
 	rootCmd.AddCommand(nmapCmd)
-	nmapCmd.Flags().StringVarP(&Target, "target", "t", "127.0.0.1", "Target CIDR range or IP address to scan")
+	nmapCmd.Flags().StringVarP(&Target, "target", "t", "127.0.0.1", "Targets to scan: IPs, CIDRs, ranges (10.0.0.5-10.0.0.40), hostnames or @file, comma separated")
+	nmapCmd.Flags().StringVar(&ExcludeNmap, "exclude", "", "Targets never to scan, in the format of --target")
+	nmapCmd.Flags().StringVar(&ExcludeFile, "exclude-file", "", "File listing targets never to scan")
 	nmapCmd.Flags().StringVarP(&Ports, "ports", "p", "", "Ports to scan on target systems (defaults to top 1000 most common ports)")
 	nmapCmd.Flags().BoolVarP(&OsDetection, "detect-os", "d", false, "Enable OS detection (requires sudo)")
+	addScopeFlags(nmapCmd)
+	nmapCmd.Flags().BoolVar(&DryRun, "dry-run", false, "Print the nmap command line without running it")
 	nmapCmd.Flags().StringVarP(&PathActive, "export", "e", "", "Export results to CSV file")
 }
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/Naman1997/discovr/internal"
+	"github.com/Naman1997/discovr/verbose"
+	"github.com/spf13/cobra"
+)
+
+var ouiCmd = &cobra.Command{
+	Use:   "oui",
+	Short: "Manage the MAC vendor (OUI) registry",
+}
+
+var ouiRefreshCmd = &cobra.Command{
+	Use:   "refresh <file>...",
+	Short: "Load the current IEEE registry from downloaded CSV files",
+	Long: `Reads IEEE registry CSV files (oui.csv, mam.csv and oui36.csv from https://standards-oui.ieee.org) and stores them next to the discovr configuration.
+The stored registry takes precedence over the snapshots built into discovr.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		count, err := internal.RefreshOUI(args)
+		if err != nil {
+			verbose.VerboseFatalfMsg("OUI refresh failed: %v", err)
+		}
+		path, _ := internal.OUICachePath()
+		fmt.Printf("Stored %d assignments in %s\n", count, path)
+	},
+}
+
+var ouiLookupCmd = &cobra.Command{
+	Use:   "lookup <mac>...",
+	Short: "Show the vendor of MAC addresses",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		for _, mac := range args {
+			vendor := internal.MACVendor(mac)
+			if vendor == "" {
+				vendor = "unknown"
+			}
+			fmt.Printf("%s\t%s\n", mac, vendor)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(ouiCmd)
+	ouiCmd.AddCommand(ouiRefreshCmd)
+	ouiCmd.AddCommand(ouiLookupCmd)
+}
@@ -1,31 +1,139 @@
 package cmd
 
 import (
+	"slices"
+	"strings"
+	"time"
+
 	"github.com/Naman1997/discovr/internal"
+	"github.com/Naman1997/discovr/verbose"
 	"github.com/spf13/cobra"
 )
 
 var (
-	Interface   string
-	ScanTime    int
-	PathPassive string
+	Interface            string
+	ScanTime             int
+	PathPassive          string
+	ReadPath             string
+	WritePath            string
+	RotateSize           int
+	RotateTime           int
+	SampleCount          int
+	BPFFilter            string
+	Promisc              bool
+	SnapLen              int
+	BufferSize           int
+	TrackAll             bool
+	Follow               bool
+	Checkpoint           int
+	AllInterfacesPassive bool
 )
 
 var passiveCmd = &cobra.Command{
 	Use:   "passive",
 	Short: "Scan local network passively",
-	Long:  `Reads incomming packets to determine devices present on the network`,
+	Long:  `Reads incomming packets to determine devices present on the network, or analyses a saved pcap/pcapng capture`,
 	Run: func(cmd *cobra.Command, args []string) {
-		internal.PassiveScan(Interface, ScanTime)
-		internal.ShowResults(internal.Passive_results)
-		internal.ExportCSV(PathPassive, internal.Passive_results)
-		internal.UploadResults(UploadUrl, PathPassive, internal.Passive_results, "passive_")
+		if err := internal.ValidateBPFFilter(BPFFilter, SnapLen); err != nil {
+			verbose.VerboseFatalfMsg("%v", err)
+		}
+		opts := internal.PassiveOptions{
+			Capture: internal.CaptureOptions{
+				Path:           WritePath,
+				MaxSizeMB:      RotateSize,
+				RotateEvery:    time.Duration(RotateTime) * time.Second,
+				SamplePerAsset: SampleCount,
+			},
+			Filter:       BPFFilter,
+			Promisc:      Promisc,
+			SnapLen:      SnapLen,
+			BufferSizeMB: BufferSize,
+			TrackAll:     TrackAll,
+		}
+		devices := passiveDevices(cmd)
+		if ReadPath == "" {
+			internal.WarnSnapLen(SnapLen)
+		}
+		switch {
+		case Follow && ReadPath != "":
+			verbose.VerboseFatalfMsg("--follow cannot be combined with --read")
+		case Follow:
+			internal.PassiveFollow(devices, opts, internal.FollowOptions{
+				ExportPath:      PathPassive,
+				CheckpointEvery: time.Duration(Checkpoint) * time.Second,
+			})
+		case ReadPath != "":
+			internal.PassiveScanFile(ReadPath, opts)
+		default:
+			internal.PassiveScan(devices, ScanTime, opts)
+		}
+		if len(internal.Neighbour_results) > 0 {
+			reportPassive(internal.Neighbour_results, internal.ExportPathWithSuffix(PathPassive, "_neighbours"), "passive_neighbours_")
+		}
+		if len(internal.PassiveDNS_results) > 0 {
+			reportPassive(internal.PassiveDNS_results, internal.ExportPathWithSuffix(PathPassive, "_dns"), "passive_dns_")
+		}
+		if TrackAll {
+			reportPassive(internal.PassiveHost_results, internal.ExportPathWithSuffix(PathPassive, "_hosts"), "passive_hosts_")
+			reportPassive(internal.PassiveFlow_results, internal.ExportPathWithSuffix(PathPassive, "_flows"), "passive_flows_")
+			return
+		}
+		reportPassive(internal.Passive_results, PathPassive, "passive_")
 	},
 }
 
+// passiveDevices resolves the capture devices from --interface, a comma
+// separated list, or --all-interfaces.
+func passiveDevices(cmd *cobra.Command) []string {
+	if !AllInterfacesPassive {
+		var devices []string
+		for _, device := range strings.Split(Interface, ",") {
+			if device = strings.TrimSpace(device); device != "" && !slices.Contains(devices, device) {
+				devices = append(devices, device)
+			}
+		}
+		if len(devices) == 0 {
+			verbose.VerboseFatalfMsg("--interface needs at least one interface name")
+		}
+		return devices
+	}
+	if cmd.Flags().Changed("interface") {
+		verbose.VerboseFatalfMsg("--all-interfaces cannot be combined with --interface")
+	}
+	devices, err := internal.CaptureInterfaces()
+	if err != nil {
+		verbose.VerboseFatalfMsg("%v", err)
+	}
+	verbose.VerbosePrintf("Capturing on all interfaces: %s\n", strings.Join(devices, ", "))
+	return devices
+}
+
+// reportPassive shows, exports and uploads one result table of a passive scan.
+// Follow mode has already written the export files in its final checkpoint.
+func reportPassive[T any](data []T, path string, prefix string) {
+	internal.ShowResults(data)
+	if !Follow {
+		internal.ExportCSV(path, data)
+	}
+	internal.UploadResults(UploadUrl, path, data, prefix)
+}
+
 func init() {
 	rootCmd.AddCommand(passiveCmd)
-	passiveCmd.Flags().StringVarP(&Interface, "interface", "i", "any", "Interface to read packets from")
+	passiveCmd.Flags().StringVarP(&Interface, "interface", "i", "any", "Interfaces to read packets from, comma separated (e.g. eth0,eth1)")
+	passiveCmd.Flags().BoolVar(&AllInterfacesPassive, "all-interfaces", false, "Capture on every interface that is up, except loopback")
 	passiveCmd.Flags().IntVarP(&ScanTime, "duration", "d", 10, "Number of seconds to run the scan")
 	passiveCmd.Flags().StringVarP(&PathPassive, "export", "e", "", "Export results to CSV file")
+	passiveCmd.Flags().StringVarP(&ReadPath, "read", "r", "", "Read packets from a pcap/pcapng file instead of an interface")
+	passiveCmd.Flags().StringVarP(&WritePath, "write", "w", "", "Record captured packets to a pcapng file")
+	passiveCmd.Flags().IntVar(&RotateSize, "rotate-size", 0, "Start a new capture file after this many MB (0 disables)")
+	passiveCmd.Flags().IntVar(&RotateTime, "rotate-interval", 0, "Start a new capture file after this many seconds (0 disables)")
+	passiveCmd.Flags().IntVar(&SampleCount, "sample", 0, "Only record the first N packets of each discovered asset (0 records everything)")
+	passiveCmd.Flags().StringVarP(&BPFFilter, "filter", "f", "", "BPF capture filter (e.g. \"arp or udp port 67\")")
+	passiveCmd.Flags().BoolVar(&Promisc, "promisc", false, "Capture in promiscuous mode")
+	passiveCmd.Flags().IntVar(&SnapLen, "snaplen", 1024, "Maximum bytes captured per packet")
+	passiveCmd.Flags().IntVar(&BufferSize, "buffer-size", 0, "Kernel capture buffer size in MB (0 uses the libpcap default)")
+	passiveCmd.Flags().BoolVar(&Follow, "follow", false, "Capture until stopped, showing assets in a live table")
+	passiveCmd.Flags().IntVar(&Checkpoint, "checkpoint", 60, "Seconds between checkpoints of the export file in follow mode")
+	passiveCmd.Flags().BoolVarP(&TrackAll, "track", "a", false, "Track every endpoint and conversation seen, not just traffic to this host (SPAN/mirror ports)")
 }
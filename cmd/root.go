@@ -8,6 +8,7 @@ import (
 )
 
 var UploadUrl string
+var DryRun bool
 var rootCmd = &cobra.Command{
 	Use:   "discovr",
 	Short: "Portable asset discovery tool for mapping your networks",
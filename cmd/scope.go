@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Naman1997/discovr/internal"
+	"github.com/Naman1997/discovr/verbose"
+	"github.com/spf13/cobra"
+)
+
+var (
+	ScopePath    string
+	AuditLogPath string
+)
+
+// addScopeFlags adds the scope guard flags to a command that sends packets
+// to targets.
+func addScopeFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&ScopePath, "scope", "", "Scope file of allowed and denied ranges and time windows; out of scope scans are refused (default $DISCOVR_SCOPE)")
+	cmd.Flags().StringVar(&AuditLogPath, "audit-log", "", "Append-only log of every scan and scope decision (default <config dir>/discovr/audit.log)")
+}
+
+// guardScan checks the expanded targets of a scan against the scope file, if
+// one is in use, and records the decision in the audit log before anything
+// is sent. Out of scope scans are refused with a report of every violation.
+// The scope is returned so replies from hosts outside it can be dropped.
+func guardScan(targets *internal.TargetSet) *internal.Scope {
+	scope, path, violations := checkScope(targets)
+
+	logPath := AuditLogPath
+	if logPath == "" {
+		var err error
+		if logPath, err = internal.AuditLogPath(); err != nil {
+			verbose.VerboseFatalfMsg("Unable to locate the audit log: %v", err)
+		}
+	}
+	if err := internal.AppendAudit(logPath, internal.NewAuditEntry(targets, scope, violations)); err != nil {
+		// A scan under contract must leave a trail
+		if scope != nil {
+			verbose.VerboseFatalfMsg("Unable to write audit log %s: %v", logPath, err)
+		}
+		verbose.Printf("Unable to write audit log %s: %v\n", logPath, err)
+	}
+
+	if len(violations) > 0 {
+		fmt.Fprintf(os.Stderr, "Scan refused: the targets are not within the authorised scope of %s\n", path)
+		for _, violation := range violations {
+			fmt.Fprintf(os.Stderr, "  - %s\n", violation)
+		}
+		fmt.Fprintf(os.Stderr, "Nothing was sent. The refusal is recorded in %s\n", logPath)
+		os.Exit(1)
+	}
+	if scope != nil {
+		verbose.VerbosePrintf("%d targets are within the scope of %s\n", targets.Count(), path)
+	}
+	return scope
+}
+
+// checkScope loads the scope file in use, if any, and checks targets
+// against it now.
+func checkScope(targets *internal.TargetSet) (*internal.Scope, string, []string) {
+	path := ScopePath
+	if path == "" {
+		path = os.Getenv("DISCOVR_SCOPE")
+	}
+	if path == "" {
+		return nil, "", nil
+	}
+	scope, err := internal.LoadScope(path)
+	if err != nil {
+		verbose.VerboseFatalfMsg("Unable to load scope file: %v", err)
+	}
+	return scope, path, scope.Check(targets, time.Now())
+}
+
+// printScopeVerdict shows what guardScan would decide for a dry run, without
+// writing to the audit log. Hostnames are only checked once they resolve.
+func printScopeVerdict(targets *internal.TargetSet, hostnames []string) {
+	scope, path, violations := checkScope(targets)
+	switch {
+	case scope == nil:
+		fmt.Println("Scope:        no scope file in use")
+	case len(hostnames) > 0 && targets.Empty():
+		fmt.Printf("Scope:        %s checked against %s once resolved at scan time\n", strings.Join(hostnames, ", "), path)
+	case len(hostnames) > 0 && len(violations) == 0:
+		fmt.Printf("Scope:        addresses within the scope of %s; %s checked once resolved at scan time\n", path, strings.Join(hostnames, ", "))
+	case len(violations) == 0:
+		fmt.Printf("Scope:        within the scope of %s\n", path)
+	default:
+		fmt.Printf("Scope:        the scan would be refused by %s\n", path)
+		for _, violation := range violations {
+			fmt.Printf("  - %s\n", violation)
+		}
+		if len(hostnames) > 0 {
+			fmt.Printf("  %s checked once resolved at scan time\n", strings.Join(hostnames, ", "))
+		}
+	}
+}
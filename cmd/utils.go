@@ -8,6 +8,8 @@ import (
 	"runtime"
 	"strings"
 
+	"github.com/Naman1997/discovr/internal"
+	"github.com/Naman1997/discovr/verbose"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/charmbracelet/huh"
@@ -118,15 +120,44 @@ func validateSubscriptionID(input string) error {
 	return nil
 }
 
-func validateIP(input string) error {
-	if input == "" {
-		return nil
+// parseTargetFlags parses the target list and exclusions shared by the
+// scanning commands. Empty targets are returned as nil.
+func parseTargetFlags(targets string, exclude string, excludeFile string) (*internal.TargetSet, *internal.TargetSet) {
+	targetSet, err := internal.ParseTargets(targets)
+	if err != nil {
+		verbose.VerboseFatalfMsg("%v", err)
 	}
-	ip := net.ParseIP(input)
-	if ip == nil {
-		return fmt.Errorf("invalid IP address")
+	excludes := []string{exclude}
+	if excludeFile != "" {
+		excludes = append(excludes, "@"+excludeFile)
 	}
-	return nil
+	excludeSet, err := internal.ParseExclusions(excludes...)
+	if err != nil {
+		verbose.VerboseFatalfMsg("%v", err)
+	}
+	return targetSet, excludeSet
+}
+
+// parseDryRunTargets parses the target flags like parseTargetFlags without
+// DNS lookups, which a dry run must not send. Hostnames are printed and
+// returned as they are, and left out of the sets.
+func parseDryRunTargets(targets string, exclude string, excludeFile string) (*internal.TargetSet, *internal.TargetSet, []string) {
+	targetSet, unresolved, err := internal.ParseTargetsOffline(targets)
+	if err != nil {
+		verbose.VerboseFatalfMsg("%v", err)
+	}
+	excludes := []string{exclude}
+	if excludeFile != "" {
+		excludes = append(excludes, "@"+excludeFile)
+	}
+	excludeSet, unresolvedExcludes, err := internal.ParseExclusionsOffline(excludes...)
+	if err != nil {
+		verbose.VerboseFatalfMsg("%v", err)
+	}
+	if len(unresolvedExcludes) > 0 {
+		fmt.Printf("Excluded:     %s (resolved at scan time)\n", strings.Join(unresolvedExcludes, ", "))
+	}
+	return targetSet, excludeSet, unresolved
 }
 
 // Allowed formats: "80,445,8080" or "22-100"
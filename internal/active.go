@@ -14,13 +14,13 @@ package internal
 import (
 	"bytes"
 	"context"
-	"encoding/binary"
 	"encoding/csv"
 	"errors"
 	"fmt"
 	"net"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -45,12 +45,24 @@ type ScanResultDfActive struct {
 	Dest_IP   string
 	Dest_Mac  string
 	Hostname  string
+	VLAN      string
+	Family    string
+	Attempts  int
+	Vendor    string
 }
 
 type ScanResultICMP struct {
-	IP       string
-	RTT      time.Duration
-	Hostname string
+	IP        string
+	RTT       time.Duration
+	Hostname  string
+	Method    string
+	Sent      int
+	Received  int
+	Loss      float64 // percent of requests without a reply
+	MinRTT    time.Duration
+	MaxRTT    time.Duration
+	StdDevRTT time.Duration
+	TTL       int
 }
 
 type HostnameResult struct {
@@ -60,88 +72,265 @@ type HostnameResult struct {
 	Err  string   // non-empty on error
 }
 
-// DefaultScan example: you can set desiredCIDR to "" to use interface mask,
-// or "192.168.0.0/28" to request scanning that CIDR (must be inside interface network).
-func DefaultScan(networkInterface string, targetCIDR string, ICMPMode bool, concurrency int, timeoutSec int, count int) {
-	netiface, err := net.InterfaceByName(networkInterface)
-	if err != nil {
-		panic(err)
+// ActiveOptions holds the settings of an active scan.
+type ActiveOptions struct {
+	Targets     *TargetSet // nil sweeps the interface networks
+	Exclude     *TargetSet // never probed
+	ICMPMode    bool       // ICMP echo instead of ARP
+	IPv6Mode    bool       // neighbor discovery instead of ARP
+	Concurrency int        // concurrent ICMP workers
+	TimeoutSec  int        // seconds to wait for each ICMP reply
+	Count       int        // ICMP requests per host
+	VLAN        int        // 802.1Q tag for ARP requests, 0 sends them untagged
+	Rate        int        // ARP requests per second across all interfaces, 0 is unlimited
+	Retries     int        // extra ARP rounds sent to hosts that have not replied
+	WaitSec     int        // seconds to wait for ARP replies after each round
+	Adaptive    bool       // back off between ARP rounds and stop once replies dry up
+	TCPPing     bool       // probe TCPPorts to find hosts that drop ICMP
+	TCPPorts    []int
+	UDPPing     bool   // probe common UDP services (DNS, NTP, SNMP)
+	Scope       *Scope // multicast responders outside it are not recorded, nil allows all
+}
+
+// maxARPHostBits caps the size of an ARP sweep at a /8.
+const maxARPHostBits = 24
+
+// DefaultScan example: you can leave opts.Targets nil to use interface mask,
+// or set it to ParseTargets("192.168.0.0/28") to request scanning that range (ARP
+// only probes the part inside the interface networks).
+// A non-zero VLAN sends 802.1Q tagged ARP requests for the target on a trunk interface.
+// ARP and IPv6 scans cover every interface in networkInterfaces in parallel,
+// ICMP, TCP and UDP ping scans use the first one.
+func DefaultScan(networkInterfaces []string, opts ActiveOptions) {
+	var netifaces []net.Interface
+	for _, name := range networkInterfaces {
+		netiface, err := net.InterfaceByName(name)
+		if err != nil {
+			panic(err)
+		}
+		netifaces = append(netifaces, *netiface)
 	}
 
-	if ICMPMode {
-		ICMPScan(netiface, targetCIDR, concurrency, timeoutSec, count)
+	if opts.ICMPMode || opts.TCPPing || opts.UDPPing {
+		if opts.ICMPMode {
+			ICMPScan(&netifaces[0], opts)
+		}
+		if opts.TCPPing || opts.UDPPing {
+			ProbeScan(&netifaces[0], opts)
+		}
+	} else if opts.IPv6Mode {
+		var wg sync.WaitGroup
+		for _, netiface := range netifaces {
+			wg.Add(1)
+			go func(netiface net.Interface) {
+				defer wg.Done()
+				NDPScan(&netiface, opts)
+			}(netiface)
+		}
+		wg.Wait()
 	} else {
-		ArpScan(netiface, targetCIDR, concurrency)
+		ArpScan(netifaces, opts)
 	}
 
 	results := DiscoverHostnamesFromScanResults(Defaultscan_results, Icmpscan_results, 20, 2)
 	if len(results) > 0 {
 		verbose.VerbosePrintf("\nDiscovered %d hostnames from scan results:\n", len(results))
 	}
+	applyPassiveDNSToActive()
 }
 
-func ArpScan(networkInterface *net.Interface, targetCIDR string, concurrency int) {
+// ArpScan sweeps every connected IPv4 subnet of the interfaces in parallel,
+// including those of secondary addresses, and merges the replies.
+func ArpScan(networkInterfaces []net.Interface, opts ActiveOptions) {
 	verbose.VerbosePrintln("Starting ARP scan...")
 	var wg sync.WaitGroup
+	limiter := newRateLimiter(opts.Rate)
 	// Find all devices
 	devices, err := pcap.FindAllDevs()
 	if err != nil {
 		panic(err)
 	}
 
-	wg.Add(1)
-	go func(netiface net.Interface) {
-		defer wg.Done()
-		if err := scan(&netiface, &devices, targetCIDR, concurrency); err != nil {
-			verbose.Printf("interface %v: %v", netiface.Name, err)
+	for _, netiface := range networkInterfaces {
+		if opts.VLAN > 0 {
+			wg.Add(1)
+			go func(netiface net.Interface) {
+				defer wg.Done()
+				if err := scanVLAN(&netiface, &devices, opts, limiter); err != nil {
+					verbose.Printf("interface %v: %v\n", netiface.Name, err)
+				}
+			}(netiface)
+			continue
 		}
-	}(*networkInterface)
+
+		addrs := arpNetworks(&netiface, opts.Targets)
+		if len(addrs) == 0 {
+			verbose.Printf("interface %v: no good IP network found\n", netiface.Name)
+		}
+		for _, addr := range addrs {
+			wg.Add(1)
+			go func(netiface net.Interface, addr *net.IPNet) {
+				defer wg.Done()
+				if err := scan(&netiface, addr, &devices, opts, limiter); err != nil {
+					verbose.Printf("interface %v (%v): %v\n", netiface.Name, addr, err)
+				}
+			}(netiface, addr)
+		}
+	}
 
 	wg.Wait()
 }
 
-func ICMPScan(netiface *net.Interface, targetCIDR string, concurrency int, timeoutSec int, count int) {
-	addr := parseNetIP(netiface)
-	if addr == nil {
-		verbose.Printf("No valid IPv4 address found on the interface.")
-		return
+// arpNetworks returns one address per IPv4 subnet of iface. With targets
+// only the subnets containing some of them are kept, or the first one so
+// scan can report the targets as out of range.
+func arpNetworks(iface *net.Interface, targets *TargetSet) []*net.IPNet {
+	var addrs []*net.IPNet
+	seen := make(map[string]bool)
+	for _, addr := range parseNetIPs(iface) {
+		network := alignToNetwork(addr).String()
+		if seen[network] || (targets != nil && targets.Intersect(networkTargets(addr)).Empty()) {
+			continue
+		}
+		seen[network] = true
+		addrs = append(addrs, addr)
+	}
+	if len(addrs) == 0 && targets != nil {
+		if addr := parseNetIP(iface); addr != nil {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+// ScanTargets expands the addresses an active scan of networkInterfaces would
+// probe, so they can be checked before the first packet is sent. IPv6
+// discovery reaches every host in the link-local and global prefixes of the
+// interfaces through multicast, so those prefixes are included whole.
+func ScanTargets(networkInterfaces []string, opts ActiveOptions) (*TargetSet, error) {
+	var netifaces []net.Interface
+	for _, name := range networkInterfaces {
+		netiface, err := net.InterfaceByName(name)
+		if err != nil {
+			return nil, err
+		}
+		netifaces = append(netifaces, *netiface)
+	}
+
+	var sets []*TargetSet
+	switch {
+	case opts.ICMPMode || opts.TCPPing || opts.UDPPing:
+		// Targets are empty, not nil, when only unresolved hostnames are given
+		if opts.Targets == nil || !opts.Targets.Empty() {
+			sets = append(sets, pingTargets(&netifaces[0], opts))
+		}
+	case opts.IPv6Mode:
+		sets = append(sets, opts.Targets.IPv6().Subtract(opts.Exclude))
+		for _, netiface := range netifaces {
+			linkLocal, globals := interfaceIPv6(&netiface)
+			var prefixes []string
+			if linkLocal != nil {
+				prefixes = append(prefixes, linkLocalPrefix)
+			}
+			for _, prefix := range globals {
+				prefixes = append(prefixes, prefix.String())
+			}
+			for _, prefix := range prefixes {
+				set, err := ParseExclusions(prefix)
+				if err != nil {
+					return nil, err
+				}
+				sets = append(sets, set)
+			}
+		}
+	case opts.VLAN > 0:
+		sets = append(sets, opts.Targets.IPv4().Subtract(opts.Exclude))
+	default:
+		for _, netiface := range netifaces {
+			for _, addr := range arpNetworks(&netiface, opts.Targets) {
+				// Subnets that scan refuses are never probed
+				if scanNet, err := arpTargets(addr, opts); err == nil {
+					sets = append(sets, scanNet)
+				}
+			}
+		}
+	}
+	return unionTargets(sets...), nil
+}
+
+// ActiveInterfaces lists the interfaces scanned by --all-interfaces: every
+// interface that is up, is not a loopback and has a MAC address.
+func ActiveInterfaces() ([]string, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 || len(iface.HardwareAddr) == 0 {
+			continue
+		}
+		names = append(names, iface.Name)
 	}
+	if len(names) == 0 {
+		return nil, errors.New("no interfaces are up")
+	}
+	return names, nil
+}
 
-	if targetCIDR == "" {
-		targetCIDR = addr.String()
+func ICMPScan(netiface *net.Interface, opts ActiveOptions) {
+	targets := pingTargets(netiface, opts)
+	if targets == nil {
+		return
 	}
 
 	// Handle Ctrl+C
 	signal.Notify(c, os.Interrupt)
-	target := targetCIDR
-	// --- Detect Single IP or CIDR ---
-	if ip, ipnet, err := net.ParseCIDR(target); err == nil {
-		verbose.VerbosePrintf("Target is a CIDR: %s (network %s)\n", target, ipnet.String())
-		runSweep(ip, ipnet, concurrency, count, time.Duration(timeoutSec)*time.Second)
-		verbose.VerbosePrintln("Ping sweep complete.")
-
-	} else if ip := net.ParseIP(target); ip != nil {
-		verbose.VerbosePrintf("Target is a single IP: %s\n", target)
-		pingHost(target, count, time.Duration(timeoutSec)*time.Second)
-	} else {
-		verbose.VerbosePrintln("Invalid input: not a valid IP or CIDR")
+	timeout := time.Duration(opts.TimeoutSec) * time.Second
+	if targets.Count() == 1 {
+		for ip := range targets.Addrs() {
+			verbose.VerbosePrintf("Target is a single IP: %s\n", ip)
+			pingHost(ip.String(), opts.Count, timeout)
+		}
+		return
 	}
+	verbose.VerbosePrintf("Pinging %d targets: %s\n", targets.Count(), targets)
+	runSweep(targets, opts.Concurrency, opts.Count, timeout)
+	verbose.VerbosePrintln("Ping sweep complete.")
 }
 
-func runSweep(ip net.IP, ipNet *net.IPNet, concurrency int, count int, timeout time.Duration) {
+// pingTargets returns the targets of a ping or probe sweep from netiface: the
+// requested targets, or else the hosts of the interface network, minus the
+// exclusions. It returns nil if there is nothing to sweep.
+func pingTargets(netiface *net.Interface, opts ActiveOptions) *TargetSet {
+	targets := opts.Targets
+	if targets == nil {
+		addr := parseNetIP(netiface)
+		if addr == nil {
+			verbose.Printf("No valid IPv4 address found on the interface.")
+			return nil
+		}
+		targets = networkTargets(addr)
+	}
+	targets = targets.Subtract(opts.Exclude)
+	if targets.Empty() {
+		verbose.Printf("Every target is excluded.\n")
+		return nil
+	}
+	return targets
+}
+
+func runSweep(targets *TargetSet, concurrency int, count int, timeout time.Duration) {
 	var wg sync.WaitGroup
 	sem := make(chan struct{}, concurrency) // limit parallel workers
 
-	for currentIP := ip.Mask(ipNet.Mask); ipNet.Contains(currentIP); incIP(currentIP) {
+	for currentIP := range targets.Addrs() {
 		select {
 		case <-c:
 			verbose.Printf("\nInterrupted.")
 			return
 		default:
 			hostIP := currentIP.String()
-			if hostIP == ipNet.IP.String() || isBroadcast(hostIP, ipNet) {
-				continue
-			}
 
 			wg.Add(1)
 			sem <- struct{}{} // acquire slot
@@ -150,23 +339,16 @@ func runSweep(ip net.IP, ipNet *net.IPNet, concurrency int, count int, timeout t
 				defer wg.Done()
 				defer func() { <-sem }() // release slot
 
-				pinger, err := probing.NewPinger(target)
+				pinger, err := newPinger(target, count, timeout)
 				if err != nil {
 					return
 				}
-				pinger.SetPrivileged(true)
-
-				pinger.Count = count
-				pinger.Interval = time.Duration(100) * time.Millisecond
-				pinger.Timeout = timeout
 				if err := pinger.Run(); err == nil && pinger.Statistics().PacketsRecv > 0 {
-					verbose.VerbosePrintf("Host alive: %-15s (avg RTT: %v)\n",
-						target, pinger.Statistics().AvgRtt)
+					stats := pinger.Statistics()
+					verbose.VerbosePrintf("Host alive: %-15s (avg RTT: %v, loss: %.0f%%)\n",
+						target, stats.AvgRtt, stats.PacketLoss)
 					mu.Lock()
-					Icmpscan_results = append(Icmpscan_results, ScanResultICMP{
-						IP:  target,
-						RTT: pinger.Statistics().AvgRtt,
-					})
+					Icmpscan_results = append(Icmpscan_results, icmpResult(target, stats))
 					mu.Unlock()
 				}
 			}(hostIP)
@@ -178,68 +360,114 @@ func runSweep(ip net.IP, ipNet *net.IPNet, concurrency int, count int, timeout t
 
 // pingHost handles single-target ping with stats
 func pingHost(target string, count int, timeout time.Duration) {
-	pinger, err := probing.NewPinger(target)
+	pinger, err := newPinger(target, count, timeout)
 	if err != nil {
 		verbose.VerbosePrintf("Cannot create pinger for %s: %v\n", target, err)
 		return
 	}
-	pinger.SetPrivileged(true)
-
-	pinger.Count = count
-	pinger.Interval = time.Duration(100) * time.Millisecond
-	pinger.Timeout = timeout
 	pinger.OnRecv = func(pkt *probing.Packet) {
 		verbose.VerbosePrintf("%d bytes from %s: icmp_seq=%d time=%v ttl=%v\n",
 			pkt.Nbytes, pkt.IPAddr, pkt.Seq, pkt.Rtt, pkt.TTL)
 	}
 	if err := pinger.Run(); err != nil {
 		verbose.VerbosePrintf("Ping failed for %s: %v\n", target, err)
+		return
+	}
+	if stats := pinger.Statistics(); stats.PacketsRecv > 0 {
+		mu.Lock()
+		Icmpscan_results = append(Icmpscan_results, icmpResult(target, stats))
+		mu.Unlock()
 	}
 }
 
-// scan now accepts targetCIDR. If targetCIDR == "" it uses interface network as before.
-func scan(iface *net.Interface, devices *[]pcap.Interface, targetCIDR string, concurrency int) error {
-	addr := parseNetIP(iface)
+// scan ARP sweeps the subnet of addr, one of the interface's subnets. With
+// opts.Targets only the targets inside it are probed.
+func scan(iface *net.Interface, addr *net.IPNet, devices *[]pcap.Interface, opts ActiveOptions, limiter *rateLimiter) error {
 	if addr == nil {
 		return errors.New("no good IP network found")
 	} else if addr.IP[0] == 127 {
 		return errors.New("skipping localhost")
-	} else if addr.Mask[0] != 0xff || addr.Mask[1] != 0xff {
-		return errors.New("mask means network is too large")
 	}
 
-	// If user supplied a targetCIDR, parse and verify it is inside addr.
-	var scanNet *net.IPNet
+	scanNet, err := arpTargets(addr, opts)
+	if err != nil {
+		return verbose.VerboseErrorf("%v", err)
+	}
 
-	if targetCIDR != "" {
-		_, tNet, err := net.ParseCIDR(targetCIDR)
-		if err != nil {
-			return verbose.VerboseErrorf("invalid target CIDR %q: %v", targetCIDR, err)
+	verbose.VerbosePrintf("Using network range %v for interface %v\n", scanNet, iface.Name)
+
+	deviceName := pcapDeviceName(devices, addr.IP)
+	if deviceName == "" {
+		return verbose.VerboseErrorf("cannot find the corresponding device for the interface %s", iface.Name)
+	}
+
+	return sweepARP(iface, deviceName, scanNet, addr.IP, opts, limiter)
+}
+
+// arpTargets returns the addresses an ARP sweep of addr, one of the
+// interface's subnets, probes. Requested targets are narrowed to the hosts of
+// the connected subnet.
+func arpTargets(addr *net.IPNet, opts ActiveOptions) (*TargetSet, error) {
+	scanNet := networkTargets(addr)
+	if opts.Targets != nil {
+		scanNet = opts.Targets.Intersect(scanNet)
+		if scanNet.Empty() {
+			return nil, fmt.Errorf("requested targets %v are outside connected interface network %v", opts.Targets, alignToNetwork(addr))
 		}
-		// align target network IP to its network base
-		tNet = alignToNetwork(tNet)
-		if !isSubnetWithin(addr, tNet) {
-			return verbose.VerboseErrorf("requested CIDR %v is outside connected interface network %v", tNet.String(), addr.String())
+	} else if ones, bits := addr.Mask.Size(); bits-ones > maxARPHostBits {
+		return nil, fmt.Errorf("network %v is too large to sweep, pass a /%d or smaller --cidr", alignToNetwork(addr), bits-maxARPHostBits)
+	}
+	if scanNet = scanNet.Subtract(opts.Exclude); scanNet.Empty() {
+		return nil, fmt.Errorf("every target in %v is excluded", alignToNetwork(addr))
+	}
+	return scanNet, nil
+}
+
+// scanVLAN ARP scans opts.Targets on a VLAN carried by a trunk interface. The
+// interface address (if any) belongs to the native VLAN, so the targets
+// must be given and the requests are sent as ARP probes from 0.0.0.0, which
+// hosts answer like any other request.
+func scanVLAN(iface *net.Interface, devices *[]pcap.Interface, opts ActiveOptions, limiter *rateLimiter) error {
+	vlan := opts.VLAN
+	if vlan > 4094 {
+		return verbose.VerboseErrorf("invalid VLAN ID %d (1-4094)", vlan)
+	}
+	if opts.Targets == nil {
+		return verbose.VerboseErrorf("scanning VLAN %d needs targets", vlan)
+	}
+	scanNet := opts.Targets.IPv4().Subtract(opts.Exclude)
+	if scanNet.Empty() {
+		return verbose.VerboseErrorf("no IPv4 targets left to scan on VLAN %d", vlan)
+	}
+
+	// Trunk ports often have no address, so fall back to the interface name
+	deviceName := iface.Name
+	if addr := parseNetIP(iface); addr != nil {
+		if name := pcapDeviceName(devices, addr.IP); name != "" {
+			deviceName = name
 		}
-		scanNet = tNet
-	} else {
-		// use interface network (align IP to network base)
-		scanNet = addr
 	}
 
-	verbose.VerbosePrintf("Using network range %v for interface %v\n", scanNet, iface.Name)
+	verbose.VerbosePrintf("Using network range %v on VLAN %d for interface %v\n", scanNet, vlan, iface.Name)
+	return sweepARP(iface, deviceName, scanNet, net.IPv4zero.To4(), opts, limiter)
+}
 
-	// find device name (same)
-	var deviceName string
+// pcapDeviceName finds the capture device that carries ip. pcap device names
+// differ from interface names on Windows.
+func pcapDeviceName(devices *[]pcap.Interface, ip net.IP) string {
 	for _, d := range *devices {
-		if strings.Contains(fmt.Sprint(d.Addresses), fmt.Sprint(addr.IP)) {
-			deviceName = d.Name
+		for _, addr := range d.Addresses {
+			if addr.IP.Equal(ip) {
+				return d.Name
+			}
 		}
 	}
-	if deviceName == "" {
-		return verbose.VerboseErrorf("cannot find the corresponding device for the interface %s", iface.Name)
-	}
+	return ""
+}
 
+// sweepARP sends an ARP request to every address of scanNet and collects the
+// replies.
+func sweepARP(iface *net.Interface, deviceName string, scanNet *TargetSet, senderIP net.IP, opts ActiveOptions, limiter *rateLimiter) error {
 	handle, err := pcap.OpenLive(deviceName, 65536, true, pcap.BlockForever)
 	if err != nil {
 		return err
@@ -247,21 +475,32 @@ func scan(iface *net.Interface, devices *[]pcap.Interface, targetCIDR string, co
 	defer handle.Close()
 
 	// Start read goroutine with stop channel
+	state := newARPSweepState()
 	stop := make(chan struct{})
-	go readARP(handle, iface, stop)
+	go readARP(handle, iface, stop, opts.VLAN, scanNet, state)
 	defer close(stop)
 
-	// write ARP only for scanNet (which may be the requested /28, /30, or the full iface /24)
-	if err := writeARP(handle, iface, scanNet, addr, concurrency); err != nil {
-		return err
+	// Every round after the first only re-probes hosts that have not replied
+	for round := 1; round <= 1+opts.Retries; round++ {
+		before := state.startRound()
+		// write ARP only for scanNet (which may be the requested targets or the full iface /24)
+		if err := writeARP(handle, iface, scanNet, senderIP, opts.VLAN, limiter, state.skip); err != nil {
+			return err
+		}
+		state.wait(opts)
+		if opts.Adaptive && round > 1 && state.replies() == before {
+			verbose.VerbosePrintf("Retry %d on %v found no new hosts, stopping\n", round-1, scanNet)
+			break
+		}
 	}
-
-	time.Sleep(3 * time.Second)
 	return nil
 }
 
-// readARP reads in packets from the pcap handle, looking for ARP replies.
-func readARP(handle *pcap.Handle, iface *net.Interface, stop chan struct{}) {
+// readARP reads in packets from the pcap handle, looking for ARP replies
+// from scanNet. Sweeps of other subnets on the same interface see the same
+// replies, so the others are left to them. A non-zero vlan only accepts
+// replies tagged with that VLAN.
+func readARP(handle *pcap.Handle, iface *net.Interface, stop chan struct{}, vlan int, scanNet *TargetSet, state *arpSweepState) {
 	src := gopacket.NewPacketSource(handle, layers.LayerTypeEthernet)
 	in := src.Packets()
 
@@ -280,105 +519,97 @@ func readARP(handle *pcap.Handle, iface *net.Interface, stop chan struct{}) {
 			if arp.Operation != layers.ARPReply || bytes.Equal([]byte(iface.HardwareAddr), arp.SourceHwAddress) {
 				continue
 			}
+			if !scanNet.Contains(net.IP(arp.SourceProtAddress)) {
+				continue
+			}
 
 			result := ScanResultDfActive{
 				Interface: iface.Name,
 				Dest_IP:   net.IP(arp.SourceProtAddress).String(),
 				Dest_Mac:  net.HardwareAddr(arp.SourceHwAddress).String(),
+				Family:    "IPv4",
 			}
-
-			key := result.Interface + "_" + result.Dest_IP + "_" + result.Dest_Mac
-			mu.Lock()
-			if seenResults[key] {
-				verbose.VerbosePrintf("Duplicate detected for %s\n", key)
-
-			} else {
-				seenResults[key] = true
-				Defaultscan_results = append(Defaultscan_results, result)
+			if vlan > 0 {
+				dot1q, ok := packet.Layer(layers.LayerTypeDot1Q).(*layers.Dot1Q)
+				if !ok || int(dot1q.VLANIdentifier) != vlan {
+					continue
+				}
+				result.VLAN = strconv.Itoa(vlan)
 			}
-			mu.Unlock()
 
-			verbose.VerbosePrintf("IP %v is at %v from interface: %v\n",
-				result.Dest_IP, result.Dest_Mac, result.Interface)
+			result.Attempts = state.reply(result.Dest_IP)
+			addDefaultResult(result)
 		}
 	}
 }
 
-// writeARP writes an ARP request for each address on our local network to thepcap handle.
-// It is a drop-in replacement for writeARP but faster for large subnets.
-func writeARP(handle *pcap.Handle, iface *net.Interface, addr *net.IPNet, intAddr *net.IPNet, concurrency int) error {
-
-	var writeMu sync.Mutex
-	sem := make(chan struct{}, concurrency)
-	var wg sync.WaitGroup
-	errCh := make(chan error, 1) // capture first error
-
-	// iterate all IPs to scan
-	for _, ip := range ips(addr, intAddr) {
-		// prepare per-ip values early
-		ip := ip // capture loop variable
-
-		// skip same as before (network/broadcast etc handled in ips)
-		sem <- struct{}{}
-		wg.Add(1)
+// addDefaultResult records a discovered host once per interface, VLAN, IP and MAC.
+func addDefaultResult(result ScanResultDfActive) {
+	result.Vendor = MACVendor(result.Dest_Mac)
+	key := result.Interface + "_" + result.VLAN + "_" + result.Dest_IP + "_" + result.Dest_Mac
+	mu.Lock()
+	if seenResults[key] {
+		verbose.VerbosePrintf("Duplicate detected for %s\n", key)
 
-		go func(targetIP net.IP) {
-			defer wg.Done()
-			defer func() { <-sem }()
-
-			// Build ethernet + arp into a fresh buffer per goroutine (no shared buffer)
-			eth := layers.Ethernet{
-				SrcMAC:       iface.HardwareAddr,
-				DstMAC:       net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
-				EthernetType: layers.EthernetTypeARP,
-			}
-			arp := layers.ARP{
-				AddrType:          layers.LinkTypeEthernet,
-				Protocol:          layers.EthernetTypeIPv4,
-				HwAddressSize:     6,
-				ProtAddressSize:   4,
-				Operation:         layers.ARPRequest,
-				SourceHwAddress:   []byte(iface.HardwareAddr),
-				SourceProtAddress: []byte(intAddr.IP),
-				DstHwAddress:      []byte{0, 0, 0, 0, 0, 0},
-				DstProtAddress:    []byte(targetIP.To4()),
-			}
-
-			buf := gopacket.NewSerializeBuffer()
-			opts := gopacket.SerializeOptions{
-				FixLengths:       true,
-				ComputeChecksums: true,
-			}
-			if err := gopacket.SerializeLayers(buf, opts, &eth, &arp); err != nil {
-				select {
-				case errCh <- err:
-				default:
-				}
-				return
-			}
-			out := buf.Bytes()
-
-			// Serialize writes with mutex to be safe on non-threadsafe pcap handles
-			writeMu.Lock()
-			if err := handle.WritePacketData(out); err != nil {
-				writeMu.Unlock()
-				select {
-				case errCh <- err:
-				default:
-				}
-				return
-			}
-			writeMu.Unlock()
-		}(ip)
+	} else {
+		seenResults[key] = true
+		Defaultscan_results = append(Defaultscan_results, result)
 	}
+	mu.Unlock()
 
-	// wait for all goroutines
-	wg.Wait()
-	close(errCh)
-	// if any error occurred, return first one
-	if err, ok := <-errCh; ok {
-		return err
+	verbose.VerbosePrintf("IP %v is at %v from interface: %v\n",
+		result.Dest_IP, result.Dest_Mac, result.Interface)
+}
+
+// writeARP writes an ARP request for each address of targets to the pcap handle.
+// Targets are generated as they are sent and the caller's goroutine is the
+// only writer, so memory stays bounded however large the network is, while
+// limiter caps the send rate. A non-zero vlan adds an 802.1Q tag to every request.
+// Targets for which skip returns true are not probed.
+func writeARP(handle *pcap.Handle, iface *net.Interface, targets *TargetSet, senderIP net.IP, vlan int, limiter *rateLimiter, skip func(net.IP) bool) error {
+	eth := layers.Ethernet{
+		SrcMAC:       iface.HardwareAddr,
+		DstMAC:       net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		EthernetType: layers.EthernetTypeARP,
+	}
+	dot1q := layers.Dot1Q{VLANIdentifier: uint16(vlan), Type: layers.EthernetTypeARP}
+	arp := layers.ARP{
+		AddrType:          layers.LinkTypeEthernet,
+		Protocol:          layers.EthernetTypeIPv4,
+		HwAddressSize:     6,
+		ProtAddressSize:   4,
+		Operation:         layers.ARPRequest,
+		SourceHwAddress:   []byte(iface.HardwareAddr),
+		SourceProtAddress: []byte(senderIP),
+		DstHwAddress:      []byte{0, 0, 0, 0, 0, 0},
+	}
+	frame := []gopacket.SerializableLayer{&eth, &arp}
+	if vlan > 0 {
+		eth.EthernetType = layers.EthernetTypeDot1Q
+		frame = []gopacket.SerializableLayer{&eth, &dot1q, &arp}
+	}
+
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{
+		FixLengths:       true,
+		ComputeChecksums: true,
+	}
+	sent := 0
+	for targetIP := range targets.Addrs() {
+		if skip(targetIP) {
+			continue
+		}
+		arp.DstProtAddress = []byte(targetIP)
+		if err := gopacket.SerializeLayers(buf, opts, frame...); err != nil {
+			return err
+		}
+		limiter.Wait()
+		if err := handle.WritePacketData(buf.Bytes()); err != nil {
+			return err
+		}
+		sent++
 	}
+	verbose.VerbosePrintf("Sent %d ARP requests on interface %v\n", sent, iface.Name)
 	return nil
 }
 
@@ -495,63 +726,6 @@ func mergeHostnamesIntoResults(arp *[]ScanResultDfActive, icmp *[]ScanResultICMP
 
 //Helper functions for ARP
 
-// ips is a simple and not very good method for getting all IPv4 addresses from a
-// net.IPNet.  It returns all IPs it can over the channel it sends back, closing
-// the channel when done.
-func ips(n *net.IPNet, o *net.IPNet) (out []net.IP) {
-
-	// n and o are different
-
-	orinum := binary.BigEndian.Uint32([]byte(o.IP))
-	orimask := binary.BigEndian.Uint32([]byte(o.Mask))
-	oribroadcast := orinum | ^orimask
-	orinetwork := orinum & orimask
-
-	num := binary.BigEndian.Uint32([]byte(n.IP))
-	mask := binary.BigEndian.Uint32([]byte(n.Mask))
-	network := num & mask
-	broadcast := network | ^mask
-
-	for ip := network; ip <= broadcast; ip++ {
-		if ip == orinetwork || ip == oribroadcast {
-			continue // skip network and broadcast addresses
-		}
-		var buf [4]byte
-		binary.BigEndian.PutUint32(buf[:], ip)
-		out = append(out, net.IP(buf[:]))
-	}
-
-	return
-}
-
-// ipToUint32 converts a 4-byte IPv4 to uint32.
-func ipToUint32(ip net.IP) uint32 {
-	return binary.BigEndian.Uint32([]byte(ip.To4()))
-}
-
-// networkRange returns network and broadcast (uint32) for a net.IPNet
-func networkRange(n *net.IPNet) (network uint32, broadcast uint32) {
-	num := ipToUint32(n.IP)
-	mask := ipToUint32(net.IP(n.Mask)) // n.Mask is 4 bytes for IPv4
-	network = num & mask
-	broadcast = network | ^mask
-	return
-}
-
-// isSubnetWithin returns true if targetNet range is fully inside ifaceNet range.
-func isSubnetWithin(ifaceNet, targetNet *net.IPNet) bool {
-	if ifaceNet == nil || targetNet == nil {
-		return false
-	}
-	if ifaceNet.IP.To4() == nil || targetNet.IP.To4() == nil {
-		return false
-	}
-	ifaceNetStart, ifaceNetEnd := networkRange(ifaceNet)
-	targetStart, targetEnd := networkRange(targetNet)
-	// target start must be >= iface start and target end <= iface end
-	return targetStart >= ifaceNetStart && targetEnd <= ifaceNetEnd
-}
-
 // alignToNetwork returns targetNet with IP aligned to its network address (useful after parsing).
 func alignToNetwork(n *net.IPNet) *net.IPNet {
 	if n == nil {
@@ -563,44 +737,30 @@ func alignToNetwork(n *net.IPNet) *net.IPNet {
 }
 
 func parseNetIP(iface *net.Interface) *net.IPNet {
-	var addr *net.IPNet
+	if addrs := parseNetIPs(iface); len(addrs) > 0 {
+		return addrs[0]
+	}
+	return nil
+}
+
+// parseNetIPs returns every IPv4 address of iface, primary first.
+func parseNetIPs(iface *net.Interface) []*net.IPNet {
+	var out []*net.IPNet
 	if addrs, err := iface.Addrs(); err != nil {
 		return nil
 	} else {
 		for _, a := range addrs {
 			if ipnet, ok := a.(*net.IPNet); ok {
 				if ip4 := ipnet.IP.To4(); ip4 != nil {
-					addr = &net.IPNet{
+					out = append(out, &net.IPNet{
 						IP:   ip4,
 						Mask: ipnet.Mask[len(ipnet.Mask)-4:],
-					}
-					break
+					})
 				}
 			}
 		}
 	}
-	return addr
-}
-
-// Helper functions for ICMP
-// incIP increments an IP address by 1
-func incIP(ip net.IP) {
-	for j := len(ip) - 1; j >= 0; j-- {
-		ip[j]++
-		if ip[j] > 0 {
-			break
-		}
-	}
-}
-
-// isBroadcast checks if IP is the broadcast address of the subnet
-func isBroadcast(ipStr string, ipNet *net.IPNet) bool {
-	ip := net.ParseIP(ipStr).To4()
-	broadcast := make(net.IP, len(ip))
-	for i := range ip {
-		broadcast[i] = ipNet.IP[i] | ^ipNet.Mask[i]
-	}
-	return ip.Equal(broadcast)
+	return out
 }
 
 // Helper functions for reverse DNS and saving results
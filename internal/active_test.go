@@ -0,0 +1,41 @@
+package internal
+
+import (
+	"net"
+	"testing"
+
+	"github.com/google/gopacket/pcap"
+)
+
+func TestPcapDeviceName(t *testing.T) {
+	device := func(name string, ips ...string) pcap.Interface {
+		d := pcap.Interface{Name: name}
+		for _, ip := range ips {
+			d.Addresses = append(d.Addresses, pcap.InterfaceAddress{IP: net.ParseIP(ip)})
+		}
+		return d
+	}
+	devices := []pcap.Interface{
+		device("eth0", "10.0.0.10", "fe80::1"),
+		device("eth1", "110.0.0.1"),
+		device("eth2", "10.0.0.1", "10.0.1.1"),
+		device("eth3", "10.0.0.1"),
+	}
+
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{"10.0.0.1", "eth2"},
+		{"10.0.0.10", "eth0"},
+		{"110.0.0.1", "eth1"},
+		{"10.0.1.1", "eth2"},
+		{"fe80::1", "eth0"},
+		{"10.0.0.2", ""},
+	}
+	for _, tt := range tests {
+		if got := pcapDeviceName(&devices, net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("pcapDeviceName(%s) = %q, want %q", tt.ip, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/Naman1997/discovr/verbose"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+const (
+	mdnsPort   = 5353
+	llmnrPort  = 5355
+	nbnsPort   = 137
+	ssdpPort   = 1900
+	mdnsSuffix = ".local"
+)
+
+// decodeAnnouncements collects the names and services that devices broadcast
+// about themselves over mDNS, LLMNR, NetBIOS name service and SSDP.
+func decodeAnnouncements(packet gopacket.Packet) {
+	udpLayer := packet.Layer(layers.LayerTypeUDP)
+	if udpLayer == nil {
+		return
+	}
+	udp := udpLayer.(*layers.UDP)
+	if len(udp.Payload) == 0 {
+		return
+	}
+
+	mac := packetSourceMAC(packet)
+	if mac == "" {
+		return
+	}
+	var srcIP net.IP
+	if ipLayer := packet.Layer(layers.LayerTypeIPv4); ipLayer != nil {
+		srcIP = ipLayer.(*layers.IPv4).SrcIP
+	} else if ipLayer := packet.Layer(layers.LayerTypeIPv6); ipLayer != nil {
+		srcIP = ipLayer.(*layers.IPv6).SrcIP
+	}
+
+	var protocol string
+	switch {
+	case udp.SrcPort == mdnsPort:
+		protocol = "mDNS"
+	case udp.SrcPort == llmnrPort:
+		protocol = "LLMNR"
+	case udp.SrcPort == nbnsPort && udp.DstPort == nbnsPort:
+		protocol = "NBNS"
+	case udp.DstPort == ssdpPort || udp.SrcPort == ssdpPort:
+		protocol = "SSDP"
+	default:
+		return
+	}
+
+	d := detailFor(mac)
+	if d.seen.IsZero() {
+		d.seen = packetTime(packet)
+		d.protocol = protocol
+	}
+	if d.ip == "" && srcIP.To4() != nil && !srcIP.IsUnspecified() {
+		d.ip = srcIP.String()
+	}
+
+	switch protocol {
+	case "mDNS":
+		decodeMDNS(d, udp.Payload, srcIP)
+	case "LLMNR":
+		decodeLLMNR(d, udp.Payload)
+	case "NBNS":
+		decodeNBNS(d, udp.Payload)
+	case "SSDP":
+		decodeSSDP(d, udp.Payload)
+	}
+}
+
+// decodeMDNS reads mDNS responses: PTR records name advertised service types,
+// SRV and A/AAAA records name the host, and TXT records often carry a model.
+func decodeMDNS(d *hostDetail, payload []byte, srcIP net.IP) {
+	var dns layers.DNS
+	if err := dns.DecodeFromBytes(payload, gopacket.NilDecodeFeedback); err != nil || !dns.QR {
+		return
+	}
+
+	records := append(dns.Answers, dns.Additionals...)
+	for _, rr := range records {
+		name := string(rr.Name)
+		switch rr.Type {
+		case layers.DNSTypePTR:
+			if name == "_services._dns-sd._udp.local" {
+				d.services[strings.TrimSuffix(string(rr.PTR), mdnsSuffix)] = struct{}{}
+			} else if isServiceType(name) {
+				d.services[strings.TrimSuffix(name, mdnsSuffix)] = struct{}{}
+			}
+		case layers.DNSTypeSRV:
+			d.setHostname(strings.TrimSuffix(string(rr.SRV.Name), mdnsSuffix))
+		case layers.DNSTypeA, layers.DNSTypeAAAA:
+			if rr.IP.Equal(srcIP) {
+				d.setHostname(strings.TrimSuffix(name, mdnsSuffix))
+			}
+		case layers.DNSTypeTXT:
+			for _, txt := range rr.TXTs {
+				key, value, ok := strings.Cut(string(txt), "=")
+				if ok && value != "" && d.deviceGuess == "" && (key == "md" || key == "model" || key == "ty" || key == "usb_MDL") {
+					d.deviceGuess = value
+				}
+			}
+		}
+	}
+	verbose.VerbosePrintf("mDNS from %s: hostname=%q services=%s\n", d.mac, d.hostname, joinSet(d.services))
+}
+
+// isServiceType reports whether an mDNS name is a DNS-SD service type such as
+// _ipp._tcp.local.
+func isServiceType(name string) bool {
+	return strings.HasPrefix(name, "_") &&
+		(strings.HasSuffix(name, "._tcp.local") || strings.HasSuffix(name, "._udp.local"))
+}
+
+// decodeLLMNR reads LLMNR responses, whose answers are the responder's own name.
+func decodeLLMNR(d *hostDetail, payload []byte) {
+	var dns layers.DNS
+	if err := dns.DecodeFromBytes(payload, gopacket.NilDecodeFeedback); err != nil || !dns.QR {
+		return
+	}
+	for _, rr := range dns.Answers {
+		if rr.Type == layers.DNSTypeA || rr.Type == layers.DNSTypeAAAA {
+			d.setHostname(string(rr.Name))
+			verbose.VerbosePrintf("LLMNR from %s: hostname=%q\n", d.mac, d.hostname)
+			return
+		}
+	}
+}
+
+// NetBIOS name suffixes, see RFC 1001 and MS-BRWS
+const (
+	nbSuffixWorkstation = 0x00
+	nbSuffixServer      = 0x20
+	nbSuffixBrowser     = 0x1e
+	nbGroupFlag         = 0x8000
+)
+
+// decodeNBNS reads NetBIOS name registrations, refreshes and positive query
+// responses. Unique names are the computer name, group names the workgroup or
+// domain.
+func decodeNBNS(d *hostDetail, payload []byte) {
+	if len(payload) < 12 {
+		return
+	}
+	qdCount := binary.BigEndian.Uint16(payload[4:6])
+	rrCount := binary.BigEndian.Uint16(payload[6:8]) + binary.BigEndian.Uint16(payload[10:12])
+	if rrCount == 0 {
+		return
+	}
+
+	offset := 12
+	var name string
+	var suffix byte
+	if qdCount > 0 {
+		var ok bool
+		name, suffix, offset, ok = readNetBIOSName(payload, offset)
+		if !ok {
+			return
+		}
+		offset += 4 // question type and class
+	}
+
+	// The resource record either repeats the name or points back at the question
+	if offset+2 <= len(payload) && payload[offset]&0xc0 == 0xc0 {
+		offset += 2
+	} else {
+		var ok bool
+		name, suffix, offset, ok = readNetBIOSName(payload, offset)
+		if !ok {
+			return
+		}
+	}
+	// type, class, TTL and RDATA length precede the NB_FLAGS of the first address
+	offset += 10
+	if offset+2 > len(payload) || name == "" {
+		return
+	}
+	group := binary.BigEndian.Uint16(payload[offset:offset+2])&nbGroupFlag != 0
+
+	switch {
+	case group && (suffix == nbSuffixWorkstation || suffix == nbSuffixBrowser):
+		d.workgroup = name
+	case !group && (suffix == nbSuffixWorkstation || suffix == nbSuffixServer):
+		d.netbiosName = name
+		d.setHostname(name)
+	default:
+		return
+	}
+	verbose.VerbosePrintf("NBNS from %s: name=%q workgroup=%q\n", d.mac, d.netbiosName, d.workgroup)
+}
+
+// readNetBIOSName decodes a first-level encoded NetBIOS name (RFC 1001 14.1)
+// starting at offset and returns the name, its suffix byte and the offset
+// after the name.
+func readNetBIOSName(payload []byte, offset int) (string, byte, int, bool) {
+	if offset+34 > len(payload) || payload[offset] != 32 {
+		return "", 0, offset, false
+	}
+	encoded := payload[offset+1 : offset+33]
+	decoded := make([]byte, 16)
+	for i := range decoded {
+		hi, lo := encoded[2*i]-'A', encoded[2*i+1]-'A'
+		if hi > 15 || lo > 15 {
+			return "", 0, offset, false
+		}
+		decoded[i] = hi<<4 | lo
+	}
+	// skip the scope ID labels up to the terminating zero
+	end := offset + 33
+	for end < len(payload) && payload[end] != 0 {
+		end += int(payload[end]) + 1
+	}
+	if end >= len(payload) {
+		return "", 0, offset, false
+	}
+	return strings.TrimRight(string(decoded[:15]), " \x00"), decoded[15], end + 1, true
+}
+
+// decodeSSDP reads SSDP NOTIFY announcements and M-SEARCH responses for the
+// UPnP device description URL and advertised device types.
+func decodeSSDP(d *hostDetail, payload []byte) {
+	reader := bufio.NewReader(bytes.NewReader(payload))
+	var header http.Header
+	if bytes.HasPrefix(payload, []byte("HTTP/")) {
+		resp, err := http.ReadResponse(reader, nil)
+		if err != nil {
+			return
+		}
+		resp.Body.Close()
+		header = resp.Header
+	} else {
+		req, err := http.ReadRequest(reader)
+		if err != nil || req.Method != "NOTIFY" {
+			return
+		}
+		header = req.Header
+	}
+
+	if location := header.Get("Location"); location != "" {
+		d.upnp[location] = struct{}{}
+	}
+	for _, key := range []string{"NT", "ST"} {
+		if deviceType, ok := upnpDeviceType(header.Get(key)); ok {
+			d.services["upnp:"+deviceType] = struct{}{}
+		}
+	}
+	if server := header.Get("Server"); server != "" && d.deviceGuess == "" {
+		d.deviceGuess = server
+	}
+	verbose.VerbosePrintf("SSDP from %s: location=%s services=%s\n", d.mac, joinSet(d.upnp), joinSet(d.services))
+}
+
+// upnpDeviceType extracts MediaRenderer from
+// urn:schemas-upnp-org:device:MediaRenderer:1.
+func upnpDeviceType(urn string) (string, bool) {
+	parts := strings.Split(urn, ":")
+	if len(parts) >= 4 && parts[0] == "urn" && parts[2] == "device" {
+		return parts[3], true
+	}
+	return "", false
+}
@@ -0,0 +1,104 @@
+package internal
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/Naman1997/discovr/verbose"
+)
+
+const (
+	// adaptiveQuiet is how long adaptive mode waits without a new reply
+	// before ending the first round early. It doubles every round.
+	adaptiveQuiet = 500 * time.Millisecond
+	adaptiveTick  = 100 * time.Millisecond
+)
+
+// arpSweepState tracks the replies of one ARP sweep across its retry rounds.
+type arpSweepState struct {
+	mu        sync.Mutex
+	round     int
+	replied   map[string]bool
+	probed    map[string]int // round each host was last probed in, from round 2 on
+	lastReply time.Time
+}
+
+func newARPSweepState() *arpSweepState {
+	return &arpSweepState{replied: make(map[string]bool), probed: make(map[string]int)}
+}
+
+// reply records an answer from ip and returns the round ip was last probed
+// in. A late reply to an earlier round is counted for that round.
+func (s *arpSweepState) reply(ip string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.replied[ip] = true
+	s.lastReply = time.Now()
+	if round, ok := s.probed[ip]; ok {
+		return round
+	}
+	return 1
+}
+
+// skip reports whether ip has replied, so retries skip it, and otherwise
+// records that ip is probed in the current round.
+func (s *arpSweepState) skip(ip net.IP) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := ip.String()
+	if s.replied[key] {
+		return true
+	}
+	if s.round > 1 {
+		s.probed[key] = s.round
+	}
+	return false
+}
+
+// startRound moves to the next round and returns how many hosts replied so far.
+func (s *arpSweepState) startRound() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.round++
+	return len(s.replied)
+}
+
+func (s *arpSweepState) replies() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.replied)
+}
+
+// wait waits for the replies of the current round. Fixed mode always waits
+// the full WaitSec. Adaptive mode doubles the wait every round and returns as
+// soon as replies stop arriving for the round's quiet period.
+func (s *arpSweepState) wait(opts ActiveOptions) {
+	wait := time.Duration(opts.WaitSec) * time.Second
+	if !opts.Adaptive {
+		time.Sleep(wait)
+		return
+	}
+
+	s.mu.Lock()
+	backoff := time.Duration(1) << (s.round - 1)
+	s.mu.Unlock()
+	wait *= backoff
+	quiet := min(adaptiveQuiet*backoff, wait)
+
+	start := time.Now()
+	for time.Since(start) < wait {
+		time.Sleep(adaptiveTick)
+		s.mu.Lock()
+		last := s.lastReply
+		s.mu.Unlock()
+		if last.Before(start) {
+			last = start
+		}
+		if time.Since(last) >= quiet {
+			verbose.VerbosePrintf("No ARP replies for %v, ending round %d after %v\n",
+				quiet, s.round, time.Since(start).Round(time.Millisecond))
+			return
+		}
+	}
+}
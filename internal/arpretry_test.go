@@ -0,0 +1,54 @@
+package internal
+
+import (
+	"net"
+	"testing"
+)
+
+func TestARPSweepStateAttempts(t *testing.T) {
+	state := newARPSweepState()
+	probe := func(ips ...string) {
+		for _, ip := range ips {
+			state.skip(net.ParseIP(ip).To4())
+		}
+	}
+
+	state.startRound()
+	probe("192.0.2.1", "192.0.2.2", "192.0.2.3", "192.0.2.4")
+	if got := state.reply("192.0.2.1"); got != 1 {
+		t.Errorf("reply in round 1 counted as attempt %d", got)
+	}
+
+	state.startRound()
+	// 192.0.2.2 answers round 1 late, before round 2 reaches it
+	if got := state.reply("192.0.2.2"); got != 1 {
+		t.Errorf("late round 1 reply counted as attempt %d", got)
+	}
+	probe("192.0.2.3", "192.0.2.4")
+	if got := state.reply("192.0.2.3"); got != 2 {
+		t.Errorf("reply to round 2 counted as attempt %d", got)
+	}
+
+	state.startRound()
+	// 192.0.2.4 answers round 2 late, during round 3
+	if got := state.reply("192.0.2.4"); got != 2 {
+		t.Errorf("late round 2 reply counted as attempt %d", got)
+	}
+
+	tests := []struct {
+		ip   string
+		skip bool
+	}{
+		{"192.0.2.1", true},
+		{"192.0.2.4", true},
+		{"192.0.2.5", false},
+	}
+	for _, tt := range tests {
+		if got := state.skip(net.ParseIP(tt.ip).To4()); got != tt.skip {
+			t.Errorf("skip(%s) = %v, want %v", tt.ip, got, tt.skip)
+		}
+	}
+	if got := state.replies(); got != 4 {
+		t.Errorf("%d replies recorded, want 4", got)
+	}
+}
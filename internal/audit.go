@@ -0,0 +1,113 @@
+package internal
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// auditTailSize bounds the read of the last audit log line.
+const auditTailSize = 64 * 1024
+
+// AuditEntry is one line of the audit log, written for every active scan
+// before its first packet is sent.
+type AuditEntry struct {
+	Time        string   `json:"time"`
+	User        string   `json:"user"`
+	Host        string   `json:"host"`
+	Command     string   `json:"command"`
+	Targets     string   `json:"targets"` // every address, as the CIDRs covering them
+	Addresses   uint64   `json:"addresses"`
+	Scope       string   `json:"scope,omitempty"`
+	ScopeSHA256 string   `json:"scope_sha256,omitempty"`
+	Decision    string   `json:"decision"`
+	Violations  []string `json:"violations,omitempty"`
+	// Previous is the SHA-256 of the preceding line, so edits and deletions
+	// break the chain.
+	Previous string `json:"previous"`
+}
+
+// AuditLogPath is where the audit log is kept unless --audit-log is given.
+func AuditLogPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "discovr", "audit.log"), nil
+}
+
+// NewAuditEntry describes a scan of targets about to be run.
+func NewAuditEntry(targets *TargetSet, scope *Scope, violations []string) AuditEntry {
+	entry := AuditEntry{
+		Time:       time.Now().Format(time.RFC3339),
+		Command:    strings.Join(os.Args, " "),
+		Targets:    strings.Join(targets.CIDRs(), " "),
+		Addresses:  targets.Count(),
+		Decision:   "permitted",
+		Violations: violations,
+	}
+	if len(violations) > 0 {
+		entry.Decision = "refused"
+	}
+	if u, err := user.Current(); err == nil {
+		entry.User = u.Username
+	}
+	entry.Host, _ = os.Hostname()
+	if scope != nil {
+		entry.Scope, _ = filepath.Abs(scope.Path)
+		entry.ScopeSHA256 = scope.Digest
+	}
+	return entry
+}
+
+// AppendAudit appends entry to the audit log at path. The file is only ever
+// opened for appending and each line carries the hash of the one before it.
+func AppendAudit(path string, entry AuditEntry) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	previous, err := lastLine(file)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(previous)
+	entry.Previous = hex.EncodeToString(sum[:])
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+// lastLine returns the last line of the audit log without its newline, or
+// nothing for a new log. Only the tail of the file is read.
+func lastLine(file *os.File) ([]byte, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := info.Size()
+	tail := make([]byte, min(size, auditTailSize))
+	if _, err := file.ReadAt(tail, size-int64(len(tail))); err != nil && err != io.EOF {
+		return nil, err
+	}
+	tail = bytes.TrimRight(tail, "\r\n")
+	return tail[bytes.LastIndexByte(tail, '\n')+1:], nil
+}
@@ -0,0 +1,95 @@
+package internal
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// verifyAuditChain returns the number of the first line whose previous hash
+// does not match the line before it, or 0 if the chain is intact.
+func verifyAuditChain(t *testing.T, path string) int {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var previous []byte
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("line %d: %v", line, err)
+		}
+		sum := sha256.Sum256(previous)
+		if entry.Previous != hex.EncodeToString(sum[:]) {
+			return line
+		}
+		previous = append([]byte(nil), scanner.Bytes()...)
+	}
+	return 0
+}
+
+func TestAppendAuditChain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "discovr", "audit.log")
+	targets, err := ParseTargets("10.0.0.1,10.0.0.3,10.0.0.5,10.0.0.7,10.0.0.9,10.0.0.11,10.0.0.16-10.0.0.31")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, violations := range [][]string{nil, {"outside the allowed ranges"}, nil} {
+		if err := AppendAudit(path, NewAuditEntry(targets, nil, violations)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if line := verifyAuditChain(t, path); line != 0 {
+		t.Fatalf("chain broken at line %d of a fresh log", line)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 3", len(lines))
+	}
+	var entry AuditEntry
+	if err := json.Unmarshal([]byte(lines[1]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	// Every target is kept, not the five range summary of String
+	if want := "10.0.0.1 10.0.0.3 10.0.0.5 10.0.0.7 10.0.0.9 10.0.0.11 10.0.0.16/28"; entry.Targets != want {
+		t.Errorf("targets = %q, want %q", entry.Targets, want)
+	}
+	if entry.Addresses != 22 || entry.Decision != "refused" {
+		t.Errorf("addresses = %d, decision = %q", entry.Addresses, entry.Decision)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm()&0077 != 0 {
+		t.Errorf("audit log mode = %v, %v, want private", info.Mode(), err)
+	}
+
+	tests := []struct {
+		name  string
+		lines []string
+		want  int
+	}{
+		{"edited", []string{lines[0], strings.Replace(lines[1], "refused", "permitted", 1), lines[2]}, 3},
+		{"deleted", []string{lines[0], lines[2]}, 2},
+		{"reordered", []string{lines[1], lines[0], lines[2]}, 1},
+	}
+	for _, tt := range tests {
+		tampered := filepath.Join(t.TempDir(), "audit.log")
+		if err := os.WriteFile(tampered, []byte(strings.Join(tt.lines, "\n")+"\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if line := verifyAuditChain(t, tampered); line != tt.want {
+			t.Errorf("%s: chain broken at line %d, want %d", tt.name, line, tt.want)
+		}
+	}
+}
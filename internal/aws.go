@@ -23,6 +23,7 @@ type AwsScanResult struct {
 	SubnetId   string
 	Hostname   string
 	Region     string
+	Vendor     string
 }
 
 func AwsScan(regionFilter string, customConfigs []string, customCredentials []string, customProfile string) {
@@ -159,6 +160,7 @@ func ProcessInstancesForRegion(cfg aws.Config, regionName string) {
 							SubnetId:   subnetID,
 							Hostname:   hostname,
 							Region:     regionName,
+							Vendor:     MACVendor(macAddress),
 						}
 						Aws_results = append(Aws_results, result)
 					}
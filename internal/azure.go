@@ -29,6 +29,7 @@ type AzureVMResult struct {
 	Vnet          string
 	PrivateIP     string
 	PublicIP      string
+	Vendor        string
 }
 
 type AzureVMData struct {
@@ -90,7 +91,7 @@ func Azurescan(subIdInput string) {
 		subID = subIdInput
 	}
 
-	verbose.VerbosePrintln("----------------------------------------\n")
+	verbose.VerbosePrintln("----------------------------------------")
 
 	ctx := context.Background()
 	cred, err := azidentity.NewDefaultAzureCredential(nil)
@@ -194,7 +195,7 @@ func Azurescan(subIdInput string) {
 
 						// separate mask from cidr
 						mask := ""
-						if cidr != "unknown" || cidr != "" {
+						if cidr != "unknown" && cidr != "" {
 							parts := strings.Split(cidr, "/")
 							if len(parts) == 2 {
 								mask = "/" + parts[1]
@@ -223,6 +224,7 @@ func Azurescan(subIdInput string) {
 					result.Subnet = strings.Join(vmInfo.Subnet, ", ")
 					result.Vnet = strings.Join(vmInfo.Vnet, ", ")
 					result.PublicIP = strings.Join(vmInfo.PublicIP, ", ")
+					result.Vendor = macVendors(result.MAC, ", ")
 
 					verbose.VerbosePrintf("\nName: %s\n", result.Name)
 					verbose.VerbosePrintf("ID: %s\n", result.UniqueID)
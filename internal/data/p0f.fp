@@ -0,0 +1,235 @@
+;
+; TCP SYN and SYN+ACK signatures in p0f v3 format.
+;
+; label = type:class:name:flavor
+;   type is s (specific) or g (generic, only used when nothing specific matches)
+;
+; sig = ver:ittl:olen:mss:wsize,scale:olayout:quirks:pclass
+;   ver     - 4, 6 or * for either
+;   ittl    - initial TTL; a trailing - means the TTL is unreliable for distance
+;   olen    - length of IPv4 options
+;   mss     - maximum segment size, * for any
+;   wsize   - window size: a number, mss*N, mtu*N, %N (multiple of N) or *
+;   scale   - window scale, * for any
+;   olayout - TCP options in order: mss, nop, ws, sok, sack, ts, eol+N, ?N
+;   quirks  - df, id+, id-, ecn, flow, seq-, ack+, ack-, uptr+, urgf+,
+;             pushf+, ts1-, ts2+, opt+, exws, bad
+;   pclass  - 0 for no payload, + for payload, * for either
+;
+
+[tcp:request]
+
+label = s:unix:Linux:4.x-6.x
+sig   = *:64:0:*:mss*44,7:mss,sok,ts,nop,ws:df,id+:0
+sig   = *:64:0:*:mss*44,8:mss,sok,ts,nop,ws:df,id+:0
+sig   = *:64:0:*:mss*44,9:mss,sok,ts,nop,ws:df,id+:0
+sig   = *:64:0:*:mss*44,10:mss,sok,ts,nop,ws:df,id+:0
+sig   = *:64:0:*:mss*45,7:mss,sok,ts,nop,ws:df,id+:0
+sig   = *:64:0:*:65495,7:mss,sok,ts,nop,ws:df,id+:0
+
+label = s:unix:Linux:3.11 and newer
+sig   = *:64:0:*:mss*20,10:mss,sok,ts,nop,ws:df,id+:0
+sig   = *:64:0:*:mss*20,7:mss,sok,ts,nop,ws:df,id+:0
+
+label = s:unix:Linux:3.1-3.10
+sig   = *:64:0:*:mss*10,4:mss,sok,ts,nop,ws:df,id+:0
+sig   = *:64:0:*:mss*10,5:mss,sok,ts,nop,ws:df,id+:0
+sig   = *:64:0:*:mss*10,6:mss,sok,ts,nop,ws:df,id+:0
+sig   = *:64:0:*:mss*10,7:mss,sok,ts,nop,ws:df,id+:0
+
+label = s:unix:Linux:2.6.x
+sig   = *:64:0:*:mss*4,6:mss,sok,ts,nop,ws:df,id+:0
+sig   = *:64:0:*:mss*4,7:mss,sok,ts,nop,ws:df,id+:0
+sig   = *:64:0:*:mss*4,8:mss,sok,ts,nop,ws:df,id+:0
+
+label = s:unix:Linux:2.4.x
+sig   = *:64:0:*:mss*4,0:mss,sok,ts,nop,ws:df,id+:0
+sig   = *:64:0:*:mss*4,1:mss,sok,ts,nop,ws:df,id+:0
+sig   = *:64:0:*:mss*4,2:mss,sok,ts,nop,ws:df,id+:0
+
+label = s:unix:Linux:2.2.x
+sig   = *:64:0:*:mss*11,0:mss,sok,ts,nop,ws:df,id+:0
+sig   = *:64:0:*:mss*20,0:mss,sok,ts,nop,ws:df,id+:0
+sig   = *:64:0:*:mss*22,0:mss,sok,ts,nop,ws:df,id+:0
+
+label = s:unix:Linux:Android
+sig   = *:64:0:*:65535,8:mss,sok,ts,nop,ws:df,id+:0
+sig   = *:64:0:*:65535,9:mss,sok,ts,nop,ws:df,id+:0
+
+label = g:unix:Linux:2.2.x-6.x
+sig   = *:64:0:*:*,*:mss,sok,ts,nop,ws:df,id+:0
+sig   = *:64:0:*:*,*:mss,sok,ts:df,id+:0
+sig   = *:64:0:*:*,*:mss,nop,nop,sok,nop,ws:df,id+:0
+sig   = *:64:0:*:*,*:mss,nop,nop,ts,nop,ws:df,id+:0
+sig   = *:64:0:*:*,*:mss,nop,nop,sok:df,id+:0
+
+label = s:win:Windows:10 or 11
+sig   = *:128:0:*:64240,8:mss,nop,ws,nop,nop,sok:df,id+:0
+sig   = *:128:0:*:65535,8:mss,nop,ws,nop,nop,sok:df,id+:0
+sig   = *:128:0:*:64800,8:mss,nop,ws,nop,nop,sok:df,id+:0
+
+label = s:win:Windows:7 or 8
+sig   = *:128:0:*:8192,0:mss,nop,nop,sok:df,id+:0
+sig   = *:128:0:*:8192,2:mss,nop,ws,nop,nop,sok:df,id+:0
+sig   = *:128:0:*:8192,8:mss,nop,ws,nop,nop,sok:df,id+:0
+sig   = *:128:0:*:8192,2:mss,nop,ws,sok,ts:df,id+:0
+
+label = s:win:Windows:XP
+sig   = *:128:0:*:16384,0:mss,nop,nop,sok:df,id+:0
+sig   = *:128:0:*:65535,0:mss,nop,nop,sok:df,id+:0
+sig   = *:128:0:*:65535,0:mss,nop,ws,nop,nop,sok:df,id+:0
+sig   = *:128:0:*:65535,1:mss,nop,ws,nop,nop,sok:df,id+:0
+sig   = *:128:0:*:65535,2:mss,nop,ws,nop,nop,sok:df,id+:0
+
+label = s:win:Windows:2000
+sig   = *:128:0:*:16384,0:mss,nop,ws,nop,nop,sok:df,id+:0
+
+label = g:win:Windows:NT kernel
+sig   = *:128:0:*:*,*:mss,nop,nop,sok:df,id+:0
+sig   = *:128:0:*:*,*:mss,nop,ws,nop,nop,sok:df,id+:0
+sig   = *:128:0:*:*,*:mss,nop,ws,sok,ts:df,id+:0
+sig   = *:128:0:*:*,*:mss,nop,ws,nop,nop,ts,nop,nop,sok:df,id+:0
+
+label = s:unix:Mac OS X:10.x
+sig   = *:64:0:*:65535,1:mss,nop,ws,nop,nop,ts,sok,eol+1:df,id+:0
+sig   = *:64:0:*:65535,3:mss,nop,ws,nop,nop,ts,sok,eol+1:df,id+:0
+
+label = s:unix:MacOS X:10.9 or newer (sometimes iPhone or iPad)
+sig   = *:64:0:*:65535,4:mss,nop,ws,nop,nop,ts,sok,eol+1:df,id+:0
+sig   = *:64:0:*:65535,5:mss,nop,ws,nop,nop,ts,sok,eol+1:df,id+:0
+sig   = *:64:0:*:65535,6:mss,nop,ws,nop,nop,ts,sok,eol+1:df,id+:0
+
+label = s:unix:iOS:iPhone or iPad
+sig   = *:64:0:*:65535,2:mss,nop,ws,nop,nop,ts,sok,eol+1:df,id+:0
+sig   = *:64:0:*:65535,3:mss,nop,ws,nop,nop,ts,sok,eol+1:df,id+:0
+
+label = s:unix:FreeBSD:9.x or newer
+sig   = *:64:0:*:65535,6:mss,nop,ws,sok,ts:df,id+:0
+sig   = *:64:0:*:65535,6:mss,nop,ws,sok,ts:df,id-:0
+
+label = s:unix:FreeBSD:8.x
+sig   = *:64:0:*:65535,3:mss,nop,ws,sok,ts:df,id+:0
+
+label = g:unix:FreeBSD:
+sig   = *:64:0:*:65535,*:mss,nop,ws,sok,ts:df,id+:0
+
+label = s:unix:OpenBSD:3.x
+sig   = *:64:0:*:16384,0:mss,nop,nop,sok,nop,ws,nop,nop,ts:df,id+:0
+
+label = s:unix:OpenBSD:4.x-5.x
+sig   = *:64:0:*:16384,3:mss,nop,nop,sok,nop,ws,nop,nop,ts:df,id+:0
+
+label = s:unix:Solaris:8
+sig   = *:64:0:*:32850,1:nop,ws,nop,nop,ts,nop,nop,sok,mss:df,id+:0
+
+label = s:unix:Solaris:10
+sig   = *:64:0:*:mss*34,0:mss,nop,ws,nop,nop,sok:df,id+:0
+
+label = s:unix:HP-UX:11.x
+sig   = *:64:0:*:32768,0:mss,ws,nop:df,id+:0
+
+label = s:other:Cisco:IOS
+sig   = 4:255:0:*:4128,0:mss::0
+sig   = 4:255:0:*:4128,0:mss:df,id+:0
+
+label = s:other:Embedded:VxWorks
+sig   = 4:64:0:*:8192,0:mss::0
+sig   = 4:64:0:*:8192,0:mss:df,id+:0
+
+label = g:other:Embedded:minimal TCP stack (lwIP, uIP or similar)
+sig   = 4:255:0:*:*,*:mss::0
+sig   = 4:64:0:*:*,*:mss::0
+sig   = 4:255:0:*:*,*:mss:df,id+:0
+
+label = s:!:NMap:SYN scan
+sig   = *:64-:0:1460:1024,0:mss::0
+sig   = *:64-:0:1460:2048,0:mss::0
+sig   = *:64-:0:1460:3072,0:mss::0
+sig   = *:64-:0:1460:4096,0:mss::0
+
+label = s:!:NMap:OS detection
+sig   = *:64-:0:265:%512,10:ws,nop,mss,ts,sok:ack+,exws:0
+sig   = *:64-:0:0:4,10:ws,nop,mss,ts,sok:exws:0
+sig   = *:64-:0:1460:1,10:ws,nop,mss,ts,sok:ts2+,exws:0
+sig   = *:64-:0:1460:%63,10:ws,nop,mss,ts,sok:ts2+,exws:0
+
+[tcp:response]
+
+label = s:unix:Linux:3.x and newer
+sig   = *:64:0:*:mss*10,0:mss:df:0
+sig   = *:64:0:*:mss*10,0:mss,sok,ts:df:0
+sig   = *:64:0:*:mss*10,0:mss,nop,nop,ts:df:0
+sig   = *:64:0:*:mss*10,0:mss,nop,nop,sok:df:0
+sig   = *:64:0:*:mss*10,*:mss,nop,ws:df:0
+sig   = *:64:0:*:mss*10,*:mss,sok,ts,nop,ws:df:0
+sig   = *:64:0:*:mss*10,*:mss,nop,nop,ts,nop,ws:df:0
+sig   = *:64:0:*:mss*10,*:mss,nop,nop,sok,nop,ws:df:0
+sig   = *:64:0:*:mss*44,*:mss,sok,ts,nop,ws:df:0
+sig   = *:64:0:*:mss*45,*:mss,sok,ts,nop,ws:df:0
+sig   = *:64:0:*:65160,*:mss,sok,ts,nop,ws:df:0
+sig   = *:64:0:*:64240,*:mss,nop,nop,sok,nop,ws:df:0
+
+label = s:unix:Linux:2.4-2.6
+sig   = *:64:0:*:mss*4,0:mss:df:0
+sig   = *:64:0:*:mss*4,0:mss,sok,ts:df:0
+sig   = *:64:0:*:mss*4,*:mss,sok,ts,nop,ws:df:0
+sig   = *:64:0:*:mss*4,*:mss,nop,nop,sok,nop,ws:df:0
+
+label = g:unix:Linux:
+sig   = *:64:0:*:*,*:mss,sok,ts,nop,ws:df:0
+sig   = *:64:0:*:*,*:mss,nop,nop,sok,nop,ws:df:0
+sig   = *:64:0:*:*,*:mss,sok,ts:df:0
+
+label = s:win:Windows:10 or 11
+sig   = *:128:0:*:65535,8:mss,nop,ws,nop,nop,sok:df,id+:0
+sig   = *:128:0:*:64240,8:mss,nop,ws,nop,nop,sok:df,id+:0
+sig   = *:128:0:*:8192,8:mss,nop,ws,nop,nop,sok:df,id+:0
+
+label = s:win:Windows:7 or 8
+sig   = *:128:0:*:8192,0:mss:df,id+:0
+sig   = *:128:0:*:8192,0:mss,sok,ts:df,id+:0
+sig   = *:128:0:*:8192,8:mss,nop,ws,sok,ts:df,id+:0
+sig   = *:128:0:*:8192,0:mss,nop,ws:df,id+:0
+sig   = *:128:0:*:8192,0:mss,nop,nop,ts:df,id+:0
+sig   = *:128:0:*:8192,0:mss,nop,nop,sok:df,id+:0
+sig   = *:128:0:*:8192,8:mss,nop,ws,nop,nop,ts:df,id+:0
+
+label = s:win:Windows:XP
+sig   = *:128:0:*:65535,0:mss:df,id+:0
+sig   = *:128:0:*:65535,0:mss,nop,ws:df,id+:0
+sig   = *:128:0:*:65535,0:mss,nop,nop,sok:df,id+:0
+sig   = *:128:0:*:65535,0:mss,nop,ws,nop,nop,sok:df,id+:0
+
+label = g:win:Windows:NT kernel
+sig   = *:128:0:*:*,*:mss:df,id+:0
+sig   = *:128:0:*:*,*:mss,nop,ws,nop,nop,sok:df,id+:0
+sig   = *:128:0:*:*,*:mss,nop,ws,sok,ts:df,id+:0
+
+label = s:unix:Mac OS X:10.x
+sig   = *:64:0:*:65535,0:mss,nop,ws,nop,nop,ts,sok,eol+1:df,id+:0
+sig   = *:64:0:*:65535,*:mss,nop,ws,nop,nop,ts,sok,eol+1:df,id+:0
+sig   = *:64:0:*:65535,*:mss,nop,ws,sok,eol+1:df,id+:0
+
+label = s:unix:FreeBSD:9.x or newer
+sig   = *:64:0:*:65535,6:mss,nop,ws,sok,ts:df,id+:0
+sig   = *:64:0:*:65535,6:mss,nop,ws,sok,ts:df,id-:0
+
+label = s:unix:OpenBSD:5.x
+sig   = *:64:0:*:16384,3:mss,nop,nop,sok,nop,ws,nop,nop,ts:df,id+:0
+
+label = s:unix:Solaris:10
+sig   = *:64:0:*:mss*37,0:mss,nop,ws,nop,nop,sok:df,id+:0
+
+label = s:other:Cisco:IOS
+sig   = 4:255:0:*:4128,0:mss::0
+sig   = 4:255:0:*:4128,0:mss:df,id+:0
+
+label = s:other:Embedded:VxWorks
+sig   = 4:64:0:*:8192,0:mss::0
+sig   = 4:64:0:*:8192,0:mss:df,id+:0
+
+label = g:other:Embedded:minimal TCP stack (lwIP, uIP or similar)
+sig   = 4:255:0:*:*,*:mss::0
+sig   = 4:64:0:*:*,*:mss::0
+sig   = 4:255:0:*:*,*:mss:df,id+:0
+sig   = 4:32:0:*:*,*:mss::0
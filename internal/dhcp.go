@@ -0,0 +1,121 @@
+package internal
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/Naman1997/discovr/verbose"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// dhcpFingerprintData maps DHCP parameter request lists (option 55) and vendor
+// class prefixes (option 60) to an OS or device type.
+//
+//go:embed data/dhcp_fingerprints.csv
+var dhcpFingerprintData string
+
+var dhcpPRLFingerprints, dhcpVendorFingerprints = loadDHCPFingerprints()
+
+func loadDHCPFingerprints() (map[string]string, map[string]string) {
+	prl := make(map[string]string)
+	vendor := make(map[string]string)
+	records, err := csv.NewReader(strings.NewReader(dhcpFingerprintData)).ReadAll()
+	if err != nil {
+		panic(err)
+	}
+	for _, record := range records[1:] {
+		switch record[0] {
+		case "prl":
+			prl[record[1]] = record[2]
+		case "vendor":
+			vendor[record[1]] = record[2]
+		}
+	}
+	return prl, vendor
+}
+
+// guessDHCPDevice matches the parameter request list exactly first, since it
+// is specific to a DHCP client implementation, then falls back to the longest
+// matching vendor class prefix.
+func guessDHCPDevice(prl string, vendorClass string) string {
+	if guess, ok := dhcpPRLFingerprints[prl]; ok {
+		return guess
+	}
+	guess, longest := "", 0
+	for prefix, g := range dhcpVendorFingerprints {
+		if len(prefix) > longest && strings.HasPrefix(vendorClass, prefix) {
+			guess, longest = g, len(prefix)
+		}
+	}
+	return guess
+}
+
+// decodeDHCP records the hostname, vendor class and device guess of DHCP
+// clients, and learns their address from server ACKs.
+func decodeDHCP(packet gopacket.Packet) {
+	dhcpLayer := packet.Layer(layers.LayerTypeDHCPv4)
+	if dhcpLayer == nil {
+		return
+	}
+	dhcp := dhcpLayer.(*layers.DHCPv4)
+	if len(dhcp.ClientHWAddr) == 0 {
+		return
+	}
+
+	d := detailFor(dhcp.ClientHWAddr.String())
+	if d.seen.IsZero() {
+		d.seen = packetTime(packet)
+		d.protocol = "DHCP"
+	}
+
+	var msgType layers.DHCPMsgType
+	var requestedIP net.IP
+	var prl []string
+	for _, opt := range dhcp.Options {
+		switch opt.Type {
+		case layers.DHCPOptMessageType:
+			if len(opt.Data) == 1 {
+				msgType = layers.DHCPMsgType(opt.Data[0])
+			}
+		case layers.DHCPOptHostname:
+			if dhcp.Operation == layers.DHCPOpRequest {
+				d.hostname = string(opt.Data)
+			}
+		case layers.DHCPOptClassID:
+			if dhcp.Operation == layers.DHCPOpRequest {
+				d.vendorClass = string(opt.Data)
+			}
+		case layers.DHCPOptRequestIP:
+			if len(opt.Data) == 4 {
+				requestedIP = net.IP(opt.Data)
+			}
+		case layers.DHCPOptParamsRequest:
+			for _, b := range opt.Data {
+				prl = append(prl, strconv.Itoa(int(b)))
+			}
+		}
+	}
+
+	if dhcp.Operation == layers.DHCPOpReply {
+		if msgType == layers.DHCPMsgTypeAck && !dhcp.YourClientIP.IsUnspecified() {
+			d.ip = dhcp.YourClientIP.String()
+		}
+		return
+	}
+
+	if !dhcp.ClientIP.IsUnspecified() {
+		d.ip = dhcp.ClientIP.String()
+	} else if requestedIP != nil {
+		d.ip = requestedIP.String()
+	}
+	if guess := guessDHCPDevice(strings.Join(prl, ","), d.vendorClass); guess != "" {
+		d.deviceGuess = guess
+	}
+
+	verbose.VerbosePrintf("DHCP %s from %s: hostname=%q vendor=%q prl=%s guess=%q\n",
+		msgType, d.mac, d.hostname, d.vendorClass, strings.Join(prl, ","), d.deviceGuess)
+}
@@ -0,0 +1,68 @@
+package internal
+
+import "testing"
+
+func TestGuessDHCPDevice(t *testing.T) {
+	tests := []struct {
+		prl         string
+		vendorClass string
+		want        string
+	}{
+		{"1,3,6,15,31,33,43,44,46,47,119,121,249,252", "MSFT 5.0", "Microsoft Windows 10/11"},
+		{"1,121,3,6,15,119,252", "", "Apple iOS/iPadOS"},
+		{"1,2,3", "MSFT 5.0", "Microsoft Windows"},
+		{"1,2,3", "android-dhcp-14", "Android"},
+		{"1,2,3", "dhcpcd-9.4.1:Linux-6.1.0:x86_64:GenuineIntel", "Linux/BSD (dhcpcd)"},
+		{"", "udhcp 1.36.1", "Embedded Linux (BusyBox udhcpc)"},
+		{"1,2,3", "xMSFT 5.0", ""},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		if got := guessDHCPDevice(tt.prl, tt.vendorClass); got != tt.want {
+			t.Errorf("guessDHCPDevice(%q, %q) = %q, want %q", tt.prl, tt.vendorClass, got, tt.want)
+		}
+	}
+}
+
+func TestGuessDHCPDeviceLongestPrefix(t *testing.T) {
+	saved := dhcpVendorFingerprints
+	t.Cleanup(func() { dhcpVendorFingerprints = saved })
+	dhcpVendorFingerprints = map[string]string{
+		"PXEClient":                  "PXE network boot client",
+		"PXEClient:Arch:00007":       "UEFI x64 network boot",
+		"PXEClient:Arch:00007:UNDI:": "UEFI x64 UNDI network boot",
+	}
+
+	tests := []struct {
+		vendorClass string
+		want        string
+	}{
+		{"PXEClient:Arch:00000:UNDI:002001", "PXE network boot client"},
+		{"PXEClient:Arch:00007", "UEFI x64 network boot"},
+		{"PXEClient:Arch:00007:UNDI:003016", "UEFI x64 UNDI network boot"},
+	}
+	for _, tt := range tests {
+		// The map is walked in random order, so repeat to catch order dependence
+		for range 20 {
+			if got := guessDHCPDevice("", tt.vendorClass); got != tt.want {
+				t.Fatalf("guessDHCPDevice(%q) = %q, want %q", tt.vendorClass, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestEmbeddedDHCPFingerprints(t *testing.T) {
+	if len(dhcpPRLFingerprints) == 0 || len(dhcpVendorFingerprints) == 0 {
+		t.Fatalf("embedded fingerprints have %d PRLs and %d vendor classes", len(dhcpPRLFingerprints), len(dhcpVendorFingerprints))
+	}
+	for prl, guess := range dhcpPRLFingerprints {
+		if prl == "" || guess == "" {
+			t.Errorf("empty PRL fingerprint %q = %q", prl, guess)
+		}
+	}
+	for prefix, guess := range dhcpVendorFingerprints {
+		if prefix == "" || guess == "" {
+			t.Errorf("empty vendor class fingerprint %q = %q", prefix, guess)
+		}
+	}
+}
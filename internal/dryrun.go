@@ -0,0 +1,306 @@
+package internal
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// PrintActivePlan describes what DefaultScan would send with the same
+// arguments, without sending anything: the interfaces and source addresses,
+// the expanded targets, the packet rate and an estimate of the duration.
+// Estimates are upper bounds, as hosts that answer are not retried and
+// adaptive waits end once replies stop. Hostnames are resolved at scan time,
+// so the plan lists them as given and counts one address for each.
+func PrintActivePlan(networkInterfaces []string, opts ActiveOptions, hostnames []string) error {
+	var netifaces []net.Interface
+	for _, name := range networkInterfaces {
+		netiface, err := net.InterfaceByName(name)
+		if err != nil {
+			return err
+		}
+		netifaces = append(netifaces, *netiface)
+	}
+
+	switch {
+	case opts.ICMPMode || opts.TCPPing || opts.UDPPing:
+		printPingPlan(&netifaces[0], opts, hostnames)
+	case opts.IPv6Mode:
+		printNDPPlan(netifaces, opts, hostnames)
+	default:
+		printARPPlan(netifaces, opts, hostnames)
+	}
+	return nil
+}
+
+// printPlanTargets prints the Targets line of a plan: the expanded addresses,
+// followed by the hostnames that are left to resolve.
+func printPlanTargets(addresses string, hostnames []string) {
+	if len(hostnames) > 0 {
+		unresolved := strings.Join(hostnames, ", ") + " (resolved at scan time, one address each below)"
+		if addresses == "" {
+			addresses = unresolved
+		} else {
+			addresses += ", plus " + unresolved
+		}
+	}
+	fmt.Printf("Targets:      %s\n", addresses)
+}
+
+func printARPPlan(netifaces []net.Interface, opts ActiveOptions, hostnames []string) {
+	rounds := 1 + opts.Retries
+	mode := "ARP sweep"
+	if opts.VLAN > 0 {
+		mode = fmt.Sprintf("ARP sweep of VLAN %d", opts.VLAN)
+	}
+	fmt.Printf("Mode:         %s, %d round(s)\n", mode, rounds)
+
+	var total uint64
+	for _, netiface := range netifaces {
+		fmt.Printf("Interface:    %s (MAC %v)\n", netiface.Name, netiface.HardwareAddr)
+		if opts.VLAN > 0 {
+			scanNet := opts.Targets.IPv4().Subtract(opts.Exclude)
+			switch {
+			case opts.Targets == nil || (scanNet.Empty() && len(hostnames) == 0):
+				fmt.Printf("  skipped: VLAN %d needs IPv4 targets\n", opts.VLAN)
+			case scanNet.Empty():
+				fmt.Printf("  from 0.0.0.0 on VLAN %d: the hostnames\n", opts.VLAN)
+			default:
+				fmt.Printf("  from 0.0.0.0 on VLAN %d: %d targets, %v\n", opts.VLAN, scanNet.Count(), scanNet)
+				total += scanNet.Count()
+			}
+			continue
+		}
+
+		// Hostnames may resolve into any connected network
+		addrs := arpNetworks(&netiface, opts.Targets)
+		if len(hostnames) > 0 {
+			addrs = arpNetworks(&netiface, nil)
+		}
+		if len(addrs) == 0 {
+			fmt.Println("  skipped: no IPv4 network")
+		}
+		for _, addr := range addrs {
+			if addr.IP[0] == 127 {
+				fmt.Printf("  %v skipped: localhost\n", alignToNetwork(addr))
+				continue
+			}
+			scanNet, err := arpTargets(addr, opts)
+			if err != nil && len(hostnames) > 0 && opts.Targets.Intersect(networkTargets(addr)).Empty() {
+				fmt.Printf("  %v from %v: the hostnames that resolve inside it\n", alignToNetwork(addr), addr.IP)
+				continue
+			}
+			if err != nil {
+				fmt.Printf("  %v skipped: %v\n", alignToNetwork(addr), err)
+				continue
+			}
+			fmt.Printf("  %v from %v: %d targets, %v\n", alignToNetwork(addr), addr.IP, scanNet.Count(), scanNet)
+			total += scanNet.Count()
+		}
+	}
+
+	if total == 0 && len(hostnames) == 0 {
+		fmt.Println("Targets:      none, nothing would be sent")
+		return
+	}
+	addresses := ""
+	if total > 0 {
+		addresses = fmt.Sprintf("%d addresses", total)
+	}
+	printPlanTargets(addresses, hostnames)
+	total += uint64(len(hostnames))
+	fmt.Printf("Packets:      up to %d ARP requests\n", total*uint64(rounds))
+	var sending time.Duration
+	if opts.Rate > 0 {
+		fmt.Printf("Rate:         %d requests/s across all interfaces\n", opts.Rate)
+		sending = time.Duration(float64(total) / float64(opts.Rate) * float64(time.Second))
+	} else {
+		fmt.Println("Rate:         unlimited")
+	}
+
+	// Subnets are swept in parallel, so the waits overlap
+	var waiting time.Duration
+	for round := 1; round <= rounds; round++ {
+		wait := time.Duration(opts.WaitSec) * time.Second
+		if opts.Adaptive {
+			wait <<= round - 1
+		}
+		waiting += wait
+	}
+	fmt.Printf("Duration:     up to %v (%v sending, %v waiting for replies)\n",
+		(time.Duration(rounds)*sending + waiting).Round(time.Second/10), (time.Duration(rounds) * sending).Round(time.Second/10), waiting)
+}
+
+func printPingPlan(netiface *net.Interface, opts ActiveOptions, hostnames []string) {
+	var methods []string
+	if opts.ICMPMode {
+		methods = append(methods, fmt.Sprintf("ICMP echo (%d per host)", opts.Count))
+	}
+	if opts.TCPPing {
+		methods = append(methods, fmt.Sprintf("TCP connect to %s", strings.Trim(fmt.Sprint(opts.TCPPorts), "[]")))
+	}
+	if opts.UDPPing {
+		var ports []string
+		for _, probe := range udpPingProbes {
+			ports = append(ports, fmt.Sprint(probe.port))
+		}
+		methods = append(methods, "UDP probes to "+strings.Join(ports, " "))
+	}
+	fmt.Printf("Mode:         %s\n", strings.Join(methods, ", then "))
+
+	source := "none"
+	if addr := parseNetIP(netiface); addr != nil {
+		source = addr.IP.String()
+	}
+	fmt.Printf("Interface:    %s (MAC %v, address %s; the source is picked by the OS routing table)\n",
+		netiface.Name, netiface.HardwareAddr, source)
+
+	var targets *TargetSet
+	if opts.Targets == nil || !opts.Targets.Empty() {
+		targets = pingTargets(netiface, opts)
+	}
+	if targets.Empty() && len(hostnames) == 0 {
+		fmt.Println("Targets:      none, nothing would be sent")
+		return
+	}
+	addresses := ""
+	if !targets.Empty() {
+		addresses = fmt.Sprintf("%d addresses, %v", targets.Count(), targets)
+	}
+	printPlanTargets(addresses, hostnames)
+	count := targets.Count() + uint64(len(hostnames))
+
+	perHost := uint64(0)
+	passes := uint64(0)
+	if opts.ICMPMode {
+		perHost += uint64(opts.Count)
+		passes++
+	}
+	if opts.TCPPing || opts.UDPPing {
+		if opts.TCPPing {
+			perHost += uint64(len(opts.TCPPorts))
+		}
+		if opts.UDPPing {
+			perHost += uint64(len(udpPingProbes))
+		}
+		passes++
+	}
+	fmt.Printf("Packets:      up to %d probes\n", count*perHost)
+	fmt.Printf("Rate:         %d hosts in flight\n", opts.Concurrency)
+
+	// Hosts that never answer hold their worker for the whole timeout
+	batches := (count + uint64(max(opts.Concurrency, 1)) - 1) / uint64(max(opts.Concurrency, 1))
+	duration := time.Duration(batches*passes) * time.Duration(opts.TimeoutSec) * time.Second
+	fmt.Printf("Duration:     up to %v (%d round(s) of %d hosts, %ds timeout)\n", duration, batches*passes, opts.Concurrency, opts.TimeoutSec)
+}
+
+func printNDPPlan(netifaces []net.Interface, opts ActiveOptions, hostnames []string) {
+	fmt.Println("Mode:         IPv6 neighbor discovery (echo to ff02::1, then Neighbor Solicitations)")
+	requested := opts.Targets.IPv6().Subtract(opts.Exclude)
+	var solicitations uint64
+	for _, netiface := range netifaces {
+		linkLocal, globals := interfaceIPv6(&netiface)
+		if linkLocal == nil {
+			fmt.Printf("Interface:    %s skipped: no IPv6 link-local address\n", netiface.Name)
+			continue
+		}
+		sources := []string{linkLocal.String()}
+		for _, global := range globals {
+			sources = append(sources, global.String())
+		}
+		fmt.Printf("Interface:    %s (MAC %v)\n", netiface.Name, netiface.HardwareAddr)
+		fmt.Printf("  echo from:  %s\n", strings.Join(sources, ", "))
+		solicitations += requested.Count() + uint64(len(hostnames))
+	}
+	if opts.Targets != nil {
+		addresses := ""
+		if !requested.Empty() {
+			addresses = fmt.Sprintf("%d IPv6 addresses solicited, %v", requested.Count(), requested)
+		}
+		if addresses != "" || len(hostnames) > 0 {
+			printPlanTargets(addresses, hostnames)
+		}
+	}
+	fmt.Println("Targets:      every host on the link, plus the EUI-64 address of each responder in every /64 prefix")
+	if solicitations > 0 {
+		fmt.Printf("Packets:      one echo per source address, %d solicitations, then one per responder and prefix\n", solicitations)
+	} else {
+		fmt.Println("Packets:      one echo per source address, then one solicitation per responder and prefix")
+	}
+	fmt.Println("Duration:     about 4s (1s for echo replies, 3s for advertisements)")
+}
+
+// PrintAwsPlan describes the EC2 API calls AwsScan would make, read from the
+// local AWS configuration only.
+func PrintAwsPlan(regionFilter string, customConfigs []string, customCredentials []string, customProfile string) {
+	fmt.Println("Dry run, no API calls are made.")
+	profile := customProfile
+	if profile == "" {
+		profile = os.Getenv("AWS_PROFILE")
+	}
+	if profile == "" {
+		profile = "default"
+	}
+	fmt.Printf("Profile:      %s\n", profile)
+	if len(customConfigs) > 0 {
+		fmt.Printf("Config:       %s\n", strings.Join(customConfigs, ", "))
+	}
+	if len(customCredentials) > 0 {
+		fmt.Printf("Credentials:  %s\n", strings.Join(customCredentials, ", "))
+	}
+	if regionFilter == "" {
+		fmt.Println("Regions:      every region returned by DescribeRegions")
+	} else {
+		fmt.Printf("Regions:      %s\n", regionFilter)
+	}
+	fmt.Println("API calls:")
+	fmt.Println("  ec2:DescribeRegions                 list the regions enabled for the account")
+	fmt.Println("  per region:")
+	fmt.Println("  ec2:DescribeInstances               paginated")
+	fmt.Println("  ec2:DescribeNetworkInterfaces       per instance, filtered on attachment.instance-id, 50 per page")
+}
+
+// PrintAzurePlan describes the Azure Resource Manager calls Azurescan would
+// make. The default subscription is read from the local Azure CLI profile.
+func PrintAzurePlan(subIdInput string) {
+	fmt.Println("Dry run, no API calls are made.")
+	subID := subIdInput
+	if subIdInput == "default" {
+		var err error
+		if subID, err = GetDefaultSubscription(); err != nil {
+			subID = fmt.Sprintf("default (not found in the Azure CLI profile: %v)", err)
+		} else {
+			subID += " (default in the Azure CLI profile)"
+		}
+	}
+	fmt.Printf("Subscription: %s\n", subID)
+	fmt.Println("Credentials:  DefaultAzureCredential (environment, workload identity, managed identity, Azure CLI)")
+	fmt.Println("API calls:")
+	fmt.Println("  GET /subscriptions/{id}/providers/Microsoft.Compute/virtualMachines              first page, to check the subscription")
+	fmt.Println("  GET /subscriptions/{id}/providers/Microsoft.Compute/virtualMachines              every page")
+	fmt.Println("  per VM NIC:")
+	fmt.Println("  GET .../Microsoft.Network/networkInterfaces/{nic}")
+	fmt.Println("  GET .../Microsoft.Network/virtualNetworks/{vnet}/subnets/{subnet}                per IP configuration")
+	fmt.Println("  GET .../Microsoft.Network/publicIPAddresses/{ip}                                 per public IP")
+}
+
+// PrintGcpPlan describes the Google Cloud API calls GcpScan would make.
+func PrintGcpPlan(credFile string, projectFilterStr string) {
+	fmt.Println("Dry run, no API calls are made.")
+	if credFile != "" {
+		fmt.Printf("Credentials:  service account %s\n", credFile)
+	} else {
+		fmt.Println("Credentials:  application default credentials")
+	}
+	if projectFilterStr == "" {
+		fmt.Println("Projects:     every project returned by projects.list")
+	} else {
+		fmt.Printf("Projects:     %s\n", strings.Join(strings.Split(projectFilterStr, ","), ", "))
+	}
+	fmt.Println("API calls:")
+	fmt.Println("  cloudresourcemanager.projects.list")
+	fmt.Println("  per project:")
+	fmt.Println("  compute.instances.aggregatedList    every zone")
+}
@@ -0,0 +1,282 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Naman1997/discovr/verbose"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"golang.org/x/term"
+)
+
+// FollowOptions holds the settings of a continuous passive scan.
+type FollowOptions struct {
+	ExportPath      string        // checkpoint destination, empty disables checkpoints
+	CheckpointEvery time.Duration // how often results are written to ExportPath
+}
+
+const (
+	followRefresh  = time.Second
+	followMaxStats = 65536
+)
+
+// followMu serialises packet processing with the table refreshes and
+// checkpoints, which all read the shared result tables.
+var followMu sync.Mutex
+
+// followStats counts the packets and last activity of every source address,
+// and followStatsDropped how many were evicted to stay within followMaxStats.
+var (
+	followStats        = make(map[string]*followStat)
+	followStatsDropped int
+)
+
+type followStat struct {
+	packets  int
+	lastSeen time.Time
+}
+
+// followRow is one line of the live table.
+type followRow struct {
+	SrcIP       string
+	SrcMAC      string
+	Interface   string
+	Hostname    string
+	DeviceGuess string
+	OSGuess     string
+	Packets     int
+	FirstSeen   string
+	LastSeen    string
+}
+
+// PassiveFollow captures on devices until interrupted, showing discovered
+// assets in a live table when attached to a terminal. Results are written to
+// the export file every CheckpointEvery and once more on exit.
+func PassiveFollow(devices []string, opts PassiveOptions, follow FollowOptions) {
+	localIPs, err := getLocalIPs()
+	if err != nil {
+		panic(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	packets, linkType := packets(ctx, sem, devices, opts)
+	recorder := newCaptureRecorder(opts.Capture, linkType)
+	defer recorder.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for p := range packets {
+			followMu.Lock()
+			processPacket(p.packet, p.iface, localIPs, opts, recorder)
+			observeFollowStats(p.packet)
+			followMu.Unlock()
+		}
+	}()
+
+	if follow.ExportPath != "" && follow.CheckpointEvery > 0 {
+		go func() {
+			ticker := time.NewTicker(follow.CheckpointEvery)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					followMu.Lock()
+					finishPassive(opts)
+					checkpointPassive(follow.ExportPath, opts.TrackAll)
+					followMu.Unlock()
+				}
+			}
+		}()
+	}
+
+	if term.IsTerminal(int(os.Stdout.Fd())) {
+		model := &followModel{table: NewTableModel([]followRow{}, GetMaxWidth()), opts: opts, device: strings.Join(devices, ",")}
+		if _, err := tea.NewProgram(model, tea.WithAltScreen()).Run(); err != nil {
+			verbose.Printf("Live table failed: %v\n", err)
+		}
+	} else {
+		verbose.Printf("Capturing on %s until interrupted\n", strings.Join(devices, ","))
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		<-signals
+		signal.Stop(signals)
+	}
+
+	// Stop the capture and wait for the last packets before the final checkpoint
+	cancel()
+	<-done
+	followMu.Lock()
+	defer followMu.Unlock()
+	finishPassive(opts)
+	if follow.ExportPath != "" {
+		checkpointPassive(follow.ExportPath, opts.TrackAll)
+	}
+}
+
+// observeFollowStats updates the packet count and last seen time of the
+// packet's source address.
+func observeFollowStats(packet gopacket.Packet) {
+	var srcIP net.IP
+	if ipLayer := packet.Layer(layers.LayerTypeIPv4); ipLayer != nil {
+		srcIP = ipLayer.(*layers.IPv4).SrcIP
+	} else if ipLayer := packet.Layer(layers.LayerTypeIPv6); ipLayer != nil {
+		srcIP = ipLayer.(*layers.IPv6).SrcIP
+	} else {
+		return
+	}
+	ts := packetTime(packet)
+	stat, ok := followStats[srcIP.String()]
+	if !ok {
+		if len(followStats) >= followMaxStats {
+			dropped := evictStale(followStats, func(s *followStat) time.Time { return s.lastSeen }, ts, followMaxStats)
+			followStatsDropped += dropped
+			verbose.VerbosePrintf("Live table statistics full (%d addresses), dropped %d stale addresses (%d so far)\n", followMaxStats, dropped, followStatsDropped)
+		}
+		stat = &followStat{}
+		followStats[srcIP.String()] = stat
+	}
+	stat.packets++
+	stat.lastSeen = ts
+}
+
+// checkpointPassive overwrites the export files with the current results,
+// using the same file names as the end of a regular passive scan.
+func checkpointPassive(exportPath string, trackAll bool) {
+	var errs []error
+	if trackAll {
+		errs = append(errs,
+			WriteCSV(ExportPathWithSuffix(exportPath, "_hosts"), PassiveHost_results),
+			WriteCSV(ExportPathWithSuffix(exportPath, "_flows"), PassiveFlow_results))
+	} else {
+		errs = append(errs, WriteCSV(exportPath, Passive_results))
+	}
+	if len(Neighbour_results) > 0 {
+		errs = append(errs, WriteCSV(ExportPathWithSuffix(exportPath, "_neighbours"), Neighbour_results))
+	}
+	if len(PassiveDNS_results) > 0 {
+		errs = append(errs, WriteCSV(ExportPathWithSuffix(exportPath, "_dns"), PassiveDNS_results))
+	}
+	for _, err := range errs {
+		if err != nil {
+			verbose.Printf("Checkpoint failed: %v\n", err)
+		}
+	}
+	verbose.VerbosePrintf("Checkpointed %d assets to %s\n", len(Passive_results), exportPath)
+}
+
+// followSnapshot builds the live table rows, most recently active first.
+func followSnapshot(opts PassiveOptions, now time.Time) []followRow {
+	followMu.Lock()
+	defer followMu.Unlock()
+	finishPassive(opts)
+
+	rows := make([]followRow, 0, len(Passive_results))
+	lastSeen := make(map[string]time.Time)
+	for _, r := range Passive_results {
+		row := followRow{
+			SrcIP:       r.SrcIP,
+			SrcMAC:      r.SrcMAC,
+			Interface:   r.Interface,
+			Hostname:    r.Hostname,
+			DeviceGuess: r.DeviceGuess,
+			OSGuess:     r.OSGuess,
+			FirstSeen:   r.FirstSeen,
+		}
+		if stat, ok := followStats[r.SrcIP]; ok {
+			row.Packets = stat.packets
+			row.LastSeen = formatAge(now.Sub(stat.lastSeen))
+			lastSeen[r.SrcIP] = stat.lastSeen
+		}
+		rows = append(rows, row)
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return lastSeen[rows[i].SrcIP].After(lastSeen[rows[j].SrcIP]) })
+	return rows
+}
+
+// formatAge renders a duration as a short "12s ago" style age.
+func formatAge(age time.Duration) string {
+	switch {
+	case age < time.Minute:
+		return fmt.Sprintf("%ds ago", int(age.Seconds()))
+	case age < time.Hour:
+		return fmt.Sprintf("%dm ago", int(age.Minutes()))
+	case age < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(age.Hours()))
+	}
+	return fmt.Sprintf("%dd ago", int(age.Hours()/24))
+}
+
+type followTickMsg time.Time
+
+func followTick() tea.Cmd {
+	return tea.Tick(followRefresh, func(t time.Time) tea.Msg { return followTickMsg(t) })
+}
+
+// followModel wraps the result table with a periodic refresh and a status line.
+type followModel struct {
+	table   *tableModel
+	opts    PassiveOptions
+	device  string
+	started time.Time
+	height  int
+	assets  int
+	dropped int
+}
+
+func (m *followModel) Init() tea.Cmd {
+	m.started = time.Now()
+	return followTick()
+}
+
+func (m *followModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case followTickMsg:
+		rows := followSnapshot(m.opts, time.Time(msg))
+		m.assets = len(rows)
+		followMu.Lock()
+		m.dropped = followStatsDropped
+		followMu.Unlock()
+		m.table.data = rows
+		columns, tableRows := BuildTable(rows, m.table.width)
+		m.table.table.SetColumns(columns)
+		m.table.table.SetRows(tableRows)
+		height := len(tableRows) + 3
+		if m.height > 0 && height > m.height-4 {
+			height = m.height - 4
+		}
+		m.table.table.SetHeight(height)
+		return m, followTick()
+	case tea.WindowSizeMsg:
+		m.height = msg.Height
+		msg.Width -= 10
+	}
+	_, cmd := m.table.Update(msg)
+	return m, cmd
+}
+
+func (m *followModel) View() string {
+	counts := fmt.Sprintf("%d assets", m.assets)
+	if m.dropped > 0 {
+		counts += fmt.Sprintf(", statistics of %d idle addresses dropped", m.dropped)
+	}
+	status := fmt.Sprintf("Following %s for %s, %s (q to stop, esc to scroll)\n",
+		m.device, time.Since(m.started).Round(time.Second), counts)
+	if m.assets == 0 {
+		return status + "Waiting for assets...\n"
+	}
+	return status + m.table.View()
+}
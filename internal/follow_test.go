@@ -0,0 +1,50 @@
+package internal
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket/layers"
+)
+
+func TestObserveFollowStatsLimit(t *testing.T) {
+	savedStats, savedDropped := followStats, followStatsDropped
+	t.Cleanup(func() { followStats, followStatsDropped = savedStats, savedDropped })
+
+	packet := buildPacket(t,
+		&layers.Ethernet{SrcMAC: net.HardwareAddr{0x00, 0x50, 0x56, 0x00, 0x00, 0x01}, DstMAC: broadcastMAC, EthernetType: layers.EthernetTypeIPv4},
+		&layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolUDP, SrcIP: net.ParseIP("192.0.2.10"), DstIP: net.ParseIP("192.0.2.1")},
+	)
+	now := packetTime(packet)
+
+	tests := []struct {
+		name    string
+		age     func(i int) time.Duration
+		dropped int
+	}{
+		{"idle addresses", func(i int) time.Duration { return trackerIdle + time.Second }, followMaxStats},
+		{"busy addresses", func(i int) time.Duration { return time.Duration(followMaxStats-i) * time.Millisecond }, followMaxStats / 10},
+	}
+	for _, tt := range tests {
+		followStats, followStatsDropped = make(map[string]*followStat), 0
+		for i := 0; i < followMaxStats; i++ {
+			followStats[fmt.Sprintf("10.0.%d.%d", i>>8, i&0xff)] = &followStat{packets: 1, lastSeen: now.Add(-tt.age(i))}
+		}
+		observeFollowStats(packet)
+		if followStatsDropped != tt.dropped {
+			t.Errorf("%s: dropped %d, want %d", tt.name, followStatsDropped, tt.dropped)
+		}
+		if len(followStats) != followMaxStats-tt.dropped+1 {
+			t.Errorf("%s: %d addresses left, want %d", tt.name, len(followStats), followMaxStats-tt.dropped+1)
+		}
+		if stat := followStats["192.0.2.10"]; stat == nil || stat.packets != 1 || !stat.lastSeen.Equal(now) {
+			t.Errorf("%s: new address recorded as %+v", tt.name, stat)
+		}
+		// The oldest entry is gone, the most recent kept
+		if _, ok := followStats["10.0.0.0"]; ok {
+			t.Errorf("%s: the stalest address was kept", tt.name)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package internal
+
+import (
+	"net"
+	"reflect"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// hostDetails holds what the passive protocol decoders learned about each
+// device, keyed by MAC address.
+var hostDetails = make(map[string]*hostDetail)
+
+// decoderRows indexes the passive results added for decoder-only devices by
+// MAC, so the repeated refreshes of --follow update them instead of adding
+// more.
+var decoderRows = make(map[string]int)
+
+type hostDetail struct {
+	mac         string
+	ip          string
+	seen        time.Time
+	protocol    string // decoder that first reported the device
+	hostname    string
+	vendorClass string
+	deviceGuess string
+	services    map[string]struct{}
+	upnp        map[string]struct{}
+	netbiosName string
+	workgroup   string
+	ipv6        map[string]struct{}
+}
+
+// detailFor returns the detail record of a MAC, creating it on first use.
+func detailFor(mac string) *hostDetail {
+	d, ok := hostDetails[mac]
+	if !ok {
+		d = &hostDetail{
+			mac:      mac,
+			services: make(map[string]struct{}),
+			upnp:     make(map[string]struct{}),
+			ipv6:     make(map[string]struct{}),
+		}
+		hostDetails[mac] = d
+	}
+	return d
+}
+
+// detailForResult returns the detail record of a MAC unless the device it
+// describes is known to use a different IP. This stops the details of a router
+// being attached to every remote address routed through it.
+func detailForResult(ip string, mac string) *hostDetail {
+	d, ok := hostDetails[mac]
+	if !ok {
+		return nil
+	}
+	if _, own := d.ipv6[ip]; own {
+		return d
+	}
+	if d.ip != "" && d.ip != ip {
+		return nil
+	}
+	return d
+}
+
+// columns returns the result columns filled from this detail record.
+func (d *hostDetail) columns() map[string]string {
+	columns := map[string]string{
+		"Hostname":    d.hostname,
+		"VendorClass": d.vendorClass,
+		"DeviceGuess": d.deviceGuess,
+		"Services":    joinSet(d.services),
+		"UPnP":        joinSet(d.upnp),
+		"NetBIOSName": d.netbiosName,
+		"Workgroup":   d.workgroup,
+	}
+	for name, value := range d.ipv6Columns() {
+		columns[name] = value
+	}
+	return columns
+}
+
+// setHostname keeps the first name a device announced for itself.
+func (d *hostDetail) setHostname(name string) {
+	if d.hostname == "" {
+		d.hostname = name
+	}
+}
+
+// setColumns copies non-empty values into the same-named string fields of the
+// result struct pointed to by result.
+func setColumns(result any, columns map[string]string) {
+	v := reflect.ValueOf(result).Elem()
+	for name, value := range columns {
+		if f := v.FieldByName(name); value != "" && f.IsValid() && f.Kind() == reflect.String {
+			f.SetString(value)
+		}
+	}
+}
+
+// applyHostDetails copies the decoded host details into the passive results.
+// Devices only seen through the decoders, such as DHCP clients broadcasting
+// for a lease or IPv6-only hosts, are added as new results when their address
+// is known. It may run any number of times, as --follow does every refresh.
+func applyHostDetails() {
+	matched := make(map[string]bool)
+	for i := range Passive_results {
+		r := &Passive_results[i]
+		if d := detailForResult(r.SrcIP, r.SrcMAC); d != nil {
+			setColumns(r, d.columns())
+			matched[d.mac] = true
+		}
+	}
+	for i := range PassiveHost_results {
+		r := &PassiveHost_results[i]
+		if d := detailForResult(r.IP, r.MAC); d != nil {
+			setColumns(r, d.columns())
+		}
+	}
+
+	var macs []string
+	for mac := range hostDetails {
+		macs = append(macs, mac)
+	}
+	sort.Strings(macs)
+	for _, mac := range macs {
+		d := hostDetails[mac]
+		ip, ethernetType := d.ip, "IPv4"
+		if ip == "" {
+			ip, ethernetType = d.primaryIPv6(), "IPv6"
+		}
+		if matched[d.mac] || ip == "" || net.ParseIP(ip).IsUnspecified() {
+			continue
+		}
+		if !slices.Contains(discoveredAssets, ip) {
+			discoveredAssets = append(discoveredAssets, ip)
+		}
+		if i, ok := decoderRows[d.mac]; ok {
+			// The device changed address since the last refresh
+			r := &Passive_results[i]
+			r.SrcIP, r.Protocol, r.EthernetType = ip, d.protocol, ethernetType
+			setColumns(r, d.columns())
+			continue
+		}
+		result := ScanResultPassive{
+			SrcIP:        ip,
+			Protocol:     d.protocol,
+			SrcMAC:       d.mac,
+			EthernetType: ethernetType,
+			FirstSeen:    d.seen.Format(time.RFC3339),
+		}
+		setColumns(&result, d.columns())
+		Passive_results = append(Passive_results, result)
+		decoderRows[d.mac] = len(Passive_results) - 1
+	}
+}
+
+// packetSourceMAC returns the link-layer source address of a packet captured
+// on Ethernet or on the Linux "any" pseudo-device.
+func packetSourceMAC(packet gopacket.Packet) string {
+	if ethLayer := packet.Layer(layers.LayerTypeEthernet); ethLayer != nil {
+		return ethLayer.(*layers.Ethernet).SrcMAC.String()
+	}
+	if sllLayer := packet.Layer(layers.LayerTypeLinuxSLL); sllLayer != nil {
+		return sllLayer.(*layers.LinuxSLL).Addr.String()
+	}
+	return ""
+}
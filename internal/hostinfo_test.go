@@ -0,0 +1,53 @@
+package internal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestApplyHostDetailsIdempotent(t *testing.T) {
+	savedResults, savedDetails, savedRows, savedAssets := Passive_results, hostDetails, decoderRows, discoveredAssets
+	t.Cleanup(func() {
+		Passive_results, hostDetails, decoderRows, discoveredAssets = savedResults, savedDetails, savedRows, savedAssets
+	})
+	Passive_results = nil
+	hostDetails = make(map[string]*hostDetail)
+	decoderRows = make(map[string]int)
+	discoveredAssets = nil
+
+	// A host seen in traffic and a DHCP client only seen by the decoder
+	Passive_results = append(Passive_results, ScanResultPassive{SrcIP: "192.0.2.10", SrcMAC: "00:50:56:00:00:01"})
+	detailFor("00:50:56:00:00:01").setHostname("printer")
+	client := detailFor("00:50:56:00:00:02")
+	client.protocol, client.seen = "DHCP", time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	client.ip = "192.0.2.20"
+	client.setHostname("laptop")
+
+	for range 3 {
+		applyHostDetails()
+	}
+	if len(Passive_results) != 2 {
+		t.Fatalf("got %d results after repeated refreshes, want 2: %+v", len(Passive_results), Passive_results)
+	}
+	if r := Passive_results[1]; r.SrcIP != "192.0.2.20" || r.Protocol != "DHCP" || r.Hostname != "laptop" {
+		t.Errorf("decoder-only row = %+v", r)
+	}
+
+	// The client gets a new lease between refreshes
+	client.ip = "192.0.2.21"
+	for range 3 {
+		applyHostDetails()
+	}
+	if len(Passive_results) != 2 {
+		t.Fatalf("got %d results after an address change, want 2: %+v", len(Passive_results), Passive_results)
+	}
+	if r := Passive_results[1]; r.SrcIP != "192.0.2.21" || r.EthernetType != "IPv4" {
+		t.Errorf("decoder-only row after lease = %+v", r)
+	}
+	if Passive_results[0].Hostname != "printer" {
+		t.Errorf("traffic row hostname = %q, want printer", Passive_results[0].Hostname)
+	}
+	if len(discoveredAssets) != 2 || discoveredAssets[0] != "192.0.2.20" || discoveredAssets[1] != "192.0.2.21" {
+		t.Errorf("discovered assets = %v, want each address once", discoveredAssets)
+	}
+}
@@ -0,0 +1,74 @@
+package internal
+
+import (
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/Naman1997/discovr/verbose"
+	probing "github.com/prometheus-community/pro-bing"
+)
+
+var (
+	icmpPrivilegeOnce sync.Once
+	icmpPrivileged    bool
+)
+
+// useRawICMP reports whether pings can use raw sockets. Without root or
+// CAP_NET_RAW, Linux and macOS still allow unprivileged ICMP datagram sockets
+// (on Linux for the groups in net.ipv4.ping_group_range), so the scan falls
+// back to those instead of failing. Windows always uses raw sockets.
+func useRawICMP() bool {
+	icmpPrivilegeOnce.Do(func() {
+		icmpPrivileged = true
+		if runtime.GOOS == "windows" {
+			return
+		}
+		pinger, err := probing.NewPinger("127.0.0.1")
+		if err != nil {
+			return
+		}
+		pinger.SetPrivileged(true)
+		pinger.Count = 1
+		pinger.Timeout = time.Second
+		if err := pinger.Run(); err != nil {
+			icmpPrivileged = false
+			verbose.Printf("Warning: raw ICMP sockets unavailable (%v), falling back to unprivileged ICMP"+
+				" (on Linux, net.ipv4.ping_group_range must include your group)\n", err)
+		}
+	})
+	return icmpPrivileged
+}
+
+// newPinger creates a pinger for target, privileged when raw sockets work.
+func newPinger(target string, count int, timeout time.Duration) (*probing.Pinger, error) {
+	pinger, err := probing.NewPinger(target)
+	if err != nil {
+		return nil, err
+	}
+	pinger.SetPrivileged(useRawICMP())
+	pinger.Count = count
+	pinger.Interval = time.Duration(100) * time.Millisecond
+	pinger.Timeout = timeout
+	return pinger, nil
+}
+
+// icmpResult builds a result from the statistics of a finished ping. TTL is
+// the one of the last reply.
+func icmpResult(target string, stats *probing.Statistics) ScanResultICMP {
+	result := ScanResultICMP{
+		IP:        target,
+		RTT:       stats.AvgRtt,
+		Method:    "icmp echo",
+		Sent:      stats.PacketsSent,
+		Received:  stats.PacketsRecv,
+		Loss:      stats.PacketLoss,
+		MinRTT:    stats.MinRtt,
+		MaxRTT:    stats.MaxRtt,
+		StdDevRTT: stats.StdDevRtt,
+	}
+	if len(stats.TTLs) > 0 {
+		result.TTL = int(stats.TTLs[len(stats.TTLs)-1])
+	}
+	return result
+}
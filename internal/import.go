@@ -0,0 +1,358 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Naman1997/discovr/verbose"
+	"github.com/Ullaakut/nmap/v3"
+)
+
+// ImportKind identifies the result set an imported file was loaded into.
+type ImportKind string
+
+const (
+	ImportArp        ImportKind = "arp"
+	ImportIcmp       ImportKind = "icmp"
+	ImportPassive    ImportKind = "passive"
+	ImportHosts      ImportKind = "passive_hosts"
+	ImportFlows      ImportKind = "passive_flows"
+	ImportNeighbours ImportKind = "passive_neighbours"
+	ImportPassiveDNS ImportKind = "passive_dns"
+	ImportNmap       ImportKind = "nmap"
+	ImportAws        ImportKind = "aws"
+	ImportAzure      ImportKind = "azure"
+	ImportGcp        ImportKind = "gcp"
+)
+
+// importTarget describes one result type that can be rebuilt from an export.
+type importTarget struct {
+	kind     ImportKind
+	fields   []string
+	loadRows func(rows []map[string]string) error
+	loadJSON func(data []byte) error
+	count    func() int
+	diff     func(start, split int) (added, removed int, show func(exportPath string))
+}
+
+var importTargets = []importTarget{
+	newImportTarget(ImportArp, &Defaultscan_results),
+	newImportTarget(ImportIcmp, &Icmpscan_results),
+	newImportTarget(ImportPassive, &Passive_results),
+	newImportTarget(ImportHosts, &PassiveHost_results),
+	newImportTarget(ImportFlows, &PassiveFlow_results),
+	newImportTarget(ImportNeighbours, &Neighbour_results),
+	newImportTarget(ImportPassiveDNS, &PassiveDNS_results),
+	newImportTarget(ImportNmap, &Active_results),
+	newImportTarget(ImportAws, &Aws_results),
+	newImportTarget(ImportAzure, &Azure_results),
+	newImportTarget(ImportGcp, &Gcp_results),
+}
+
+func newImportTarget[T any](kind ImportKind, dst *[]T) importTarget {
+	elemType := reflect.TypeFor[T]()
+	var fields []string
+	for i := 0; i < elemType.NumField(); i++ {
+		fields = append(fields, elemType.Field(i).Name)
+	}
+
+	return importTarget{
+		kind:   kind,
+		fields: fields,
+		loadRows: func(rows []map[string]string) error {
+			for i, row := range rows {
+				var item T
+				if err := decodeRow(reflect.ValueOf(&item).Elem(), row); err != nil {
+					return fmt.Errorf("row %d: %w", i+1, err)
+				}
+				*dst = append(*dst, item)
+			}
+			return nil
+		},
+		loadJSON: func(data []byte) error {
+			var items []T
+			if err := json.Unmarshal(data, &items); err != nil {
+				return err
+			}
+			*dst = append(*dst, items...)
+			return nil
+		},
+		count: func() int { return len(*dst) },
+		diff: func(start, split int) (int, int, func(string)) {
+			older, newer := (*dst)[start:split], (*dst)[split:]
+			added, removed := diffRows(older, newer), diffRows(newer, older)
+			*dst = (*dst)[:start]
+			return len(added), len(removed), func(exportPath string) {
+				if len(added) > 0 {
+					fmt.Printf("Added: %d rows\n", len(added))
+					ShowResults(added)
+					ExportCSV(ExportPathWithSuffix(exportPath, "_added"), added)
+				}
+				if len(removed) > 0 {
+					fmt.Printf("Removed: %d rows\n", len(removed))
+					ShowResults(removed)
+					ExportCSV(ExportPathWithSuffix(exportPath, "_removed"), removed)
+				}
+			}
+		},
+	}
+}
+
+// DiffImports compares two exports of the same scan type, in any format
+// ImportFile reads. Rows only in newPath are shown as added and rows only in
+// oldPath as removed, and exported next to exportPath with _added and
+// _removed suffixes. Rows are compared whole, so a row that changed is both
+// removed and added.
+func DiffImports(oldPath, newPath, exportPath string) error {
+	// Rows loaded before oldPath are not part of the comparison
+	before := make(map[ImportKind]int)
+	for _, target := range importTargets {
+		before[target.kind] = target.count()
+	}
+	oldKind, err := ImportFile(oldPath)
+	if err != nil {
+		return fmt.Errorf("%s: %w", oldPath, err)
+	}
+	target := importTargetOf(oldKind)
+	start, split := before[oldKind], target.count()
+	newKind, err := ImportFile(newPath)
+	if err != nil {
+		return fmt.Errorf("%s: %w", newPath, err)
+	}
+	if newKind != oldKind {
+		return fmt.Errorf("cannot compare %s results in %s with %s results in %s", oldKind, oldPath, newKind, newPath)
+	}
+
+	added, removed, show := target.diff(start, split)
+	if added == 0 && removed == 0 {
+		fmt.Printf("No differences between the %s results of %s and %s\n", oldKind, oldPath, newPath)
+		return nil
+	}
+	show(exportPath)
+	return nil
+}
+
+func importTargetOf(kind ImportKind) importTarget {
+	for _, target := range importTargets {
+		if target.kind == kind {
+			return target
+		}
+	}
+	panic(fmt.Sprintf("no import target for %s", kind))
+}
+
+// diffRows returns the rows of b that are not in a, counting duplicates, as
+// rendered by ExportCSV.
+func diffRows[T any](a, b []T) []T {
+	seen := make(map[string]int)
+	for _, row := range a {
+		seen[rowKey(row)]++
+	}
+	var out []T
+	for _, row := range b {
+		key := rowKey(row)
+		if seen[key] > 0 {
+			seen[key]--
+			continue
+		}
+		out = append(out, row)
+	}
+	return out
+}
+
+func rowKey[T any](row T) string {
+	v := reflect.ValueOf(row)
+	fields := make([]string, v.NumField())
+	for i := range fields {
+		fields[i] = fmt.Sprint(v.Field(i).Interface())
+	}
+	return strings.Join(fields, "\x00")
+}
+
+// ImportFile loads a CSV or JSON export written by any discovr command, or an
+// XML report written by a standalone nmap run, into the matching results slice.
+func ImportFile(filePath string) (ImportKind, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return "", fmt.Errorf("error reading file: %v", err)
+	}
+	data = bytes.TrimPrefix(data, []byte("\uFEFF"))
+	trimmed := bytes.TrimSpace(data)
+
+	switch {
+	case strings.EqualFold(filepath.Ext(filePath), ".xml") || bytes.HasPrefix(trimmed, []byte("<")):
+		return importNmapXML(data)
+	case strings.EqualFold(filepath.Ext(filePath), ".json") || bytes.HasPrefix(trimmed, []byte("[")):
+		return importJSON(data)
+	default:
+		return importCSV(data)
+	}
+}
+
+func importNmapXML(data []byte) (ImportKind, error) {
+	var result nmap.Run
+	if err := nmap.Parse(data, &result); err != nil {
+		return "", fmt.Errorf("failed to parse nmap XML: %w", err)
+	}
+	collectNmapResults(&result)
+	verbose.VerbosePrintf("Imported nmap report with %d hosts\n", len(result.Hosts))
+	return ImportNmap, nil
+}
+
+func importCSV(data []byte) (ImportKind, error) {
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return "", fmt.Errorf("failed to parse CSV: %w", err)
+	}
+	if len(records) == 0 {
+		return "", fmt.Errorf("CSV file is empty")
+	}
+
+	headers := records[0]
+	target, err := matchImportTarget(headers)
+	if err != nil {
+		return "", err
+	}
+
+	var rows []map[string]string
+	for _, record := range records[1:] {
+		row := make(map[string]string, len(headers))
+		for i, h := range headers {
+			if i < len(record) {
+				row[h] = record[i]
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	if err := target.loadRows(rows); err != nil {
+		return "", err
+	}
+	verbose.VerbosePrintf("Imported %d %s results from CSV\n", len(rows), target.kind)
+	return target.kind, nil
+}
+
+func importJSON(data []byte) (ImportKind, error) {
+	var objects []map[string]json.RawMessage
+	if err := json.Unmarshal(data, &objects); err != nil {
+		return "", fmt.Errorf("failed to parse JSON: %w", err)
+	}
+	if len(objects) == 0 {
+		return "", fmt.Errorf("JSON file contains no results")
+	}
+
+	var keys []string
+	for k := range objects[0] {
+		keys = append(keys, k)
+	}
+	target, err := matchImportTarget(keys)
+	if err != nil {
+		return "", err
+	}
+
+	if err := target.loadJSON(data); err != nil {
+		return "", fmt.Errorf("failed to decode %s results: %w", target.kind, err)
+	}
+	verbose.VerbosePrintf("Imported %d %s results from JSON\n", len(objects), target.kind)
+	return target.kind, nil
+}
+
+// matchImportTarget picks the result type that shares the most names with
+// the columns, and of those the one with the fewest other fields. Columns no
+// type has are ignored, so exports from older releases still load, but more
+// than half of them must be known.
+func matchImportTarget(columns []string) (importTarget, error) {
+	best := -1
+	bestScore := 0
+	for i, target := range importTargets {
+		score := 0
+		for _, c := range columns {
+			if contains(target.fields, strings.TrimSpace(c)) {
+				score++
+			}
+		}
+		if score > bestScore || (score == bestScore && best >= 0 && len(target.fields) < len(importTargets[best].fields)) {
+			best, bestScore = i, score
+		}
+	}
+	if best < 0 || bestScore*2 <= len(columns) {
+		return importTarget{}, fmt.Errorf("unrecognised columns %v: not a discovr export", columns)
+	}
+	return importTargets[best], nil
+}
+
+// decodeRow fills the fields of v from their string representation as written
+// by ExportCSV.
+func decodeRow(v reflect.Value, row map[string]string) error {
+	for i := 0; i < v.NumField(); i++ {
+		name := v.Type().Field(i).Name
+		raw, ok := row[name]
+		if !ok || raw == "" {
+			continue
+		}
+		if err := setField(v.Field(i), raw); err != nil {
+			return fmt.Errorf("column %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func setField(f reflect.Value, raw string) error {
+	switch f.Interface().(type) {
+	case time.Duration:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		f.SetInt(int64(d))
+		return nil
+	case time.Time:
+		raw, _, _ = strings.Cut(raw, " m=")
+		t, err := time.Parse("2006-01-02 15:04:05.999999999 -0700 MST", raw)
+		if err != nil {
+			return err
+		}
+		f.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		f.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return err
+		}
+		f.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			return err
+		}
+		f.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		f.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported field type %s", f.Type())
+	}
+	return nil
+}
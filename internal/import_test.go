@@ -0,0 +1,220 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sampleRow returns a T with every field set to a distinct non-zero value, so
+// a column that is dropped or misread on import shows up as a difference.
+func sampleRow[T any](t *testing.T) T {
+	t.Helper()
+	var item T
+	v := reflect.ValueOf(&item).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Field(i)
+		switch f.Interface().(type) {
+		case time.Duration:
+			f.SetInt(int64(1500*time.Millisecond + time.Duration(i)))
+			continue
+		case time.Time:
+			f.Set(reflect.ValueOf(time.Date(2026, 10, 19, 12, 0, i, 42, time.UTC)))
+			continue
+		}
+		switch f.Kind() {
+		case reflect.String:
+			f.SetString(v.Type().Field(i).Name + ", value")
+		case reflect.Bool:
+			f.SetBool(true)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			f.SetInt(int64(i + 1))
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			f.SetUint(uint64(i + 1))
+		case reflect.Float32, reflect.Float64:
+			f.SetFloat(float64(i) + 0.5)
+		default:
+			t.Fatalf("%s.%s: no sample for %s", v.Type().Name(), v.Type().Field(i).Name, f.Type())
+		}
+	}
+	return item
+}
+
+// roundTrip exports a sample row of T as CSV and JSON, imports both into dst
+// and checks the same row comes back as the same kind.
+func roundTrip[T any](t *testing.T, kind ImportKind, dst *[]T) {
+	t.Helper()
+	saved := *dst
+	t.Cleanup(func() { *dst = saved })
+	want := sampleRow[T](t)
+
+	var csvData bytes.Buffer
+	if err := writeCSV(&csvData, []T{want}); err != nil {
+		t.Fatal(err)
+	}
+	jsonData, err := json.Marshal([]T{want})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for format, data := range map[string][]byte{"csv": csvData.Bytes(), "json": jsonData} {
+		*dst = nil
+		var got ImportKind
+		var err error
+		if format == "csv" {
+			got, err = importCSV(data)
+		} else {
+			got, err = importJSON(data)
+		}
+		if err != nil {
+			t.Errorf("%s %s: %v", kind, format, err)
+			continue
+		}
+		if got != kind {
+			t.Errorf("%s %s: imported as %s", kind, format, got)
+		}
+		if len(*dst) != 1 || !reflect.DeepEqual((*dst)[0], want) {
+			t.Errorf("%s %s: imported %+v, want %+v", kind, format, *dst, want)
+		}
+	}
+}
+
+func TestImportRoundTrip(t *testing.T) {
+	tests := []struct {
+		kind ImportKind
+		run  func(t *testing.T, kind ImportKind)
+	}{
+		{ImportArp, func(t *testing.T, k ImportKind) { roundTrip(t, k, &Defaultscan_results) }},
+		{ImportIcmp, func(t *testing.T, k ImportKind) { roundTrip(t, k, &Icmpscan_results) }},
+		{ImportPassive, func(t *testing.T, k ImportKind) { roundTrip(t, k, &Passive_results) }},
+		{ImportHosts, func(t *testing.T, k ImportKind) { roundTrip(t, k, &PassiveHost_results) }},
+		{ImportFlows, func(t *testing.T, k ImportKind) { roundTrip(t, k, &PassiveFlow_results) }},
+		{ImportNeighbours, func(t *testing.T, k ImportKind) { roundTrip(t, k, &Neighbour_results) }},
+		{ImportPassiveDNS, func(t *testing.T, k ImportKind) { roundTrip(t, k, &PassiveDNS_results) }},
+		{ImportNmap, func(t *testing.T, k ImportKind) { roundTrip(t, k, &Active_results) }},
+		{ImportAws, func(t *testing.T, k ImportKind) { roundTrip(t, k, &Aws_results) }},
+		{ImportAzure, func(t *testing.T, k ImportKind) { roundTrip(t, k, &Azure_results) }},
+		{ImportGcp, func(t *testing.T, k ImportKind) { roundTrip(t, k, &Gcp_results) }},
+	}
+	if len(tests) != len(importTargets) {
+		t.Fatalf("%d result types are tested, %d can be imported", len(tests), len(importTargets))
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.kind), func(t *testing.T) { tt.run(t, tt.kind) })
+	}
+}
+
+func TestImportCSVErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"empty", ""},
+		{"unknown columns", "Name,Colour\nbox,red\n"},
+		{"bad number", "IP,RTT,Hostname\n192.0.2.1,fast,host\n"},
+	}
+	for _, tt := range tests {
+		if _, err := importCSV([]byte(tt.data)); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
+
+func TestMatchImportTarget(t *testing.T) {
+	tests := []struct {
+		name    string
+		columns []string
+		want    ImportKind
+	}{
+		{"arp export", []string{"Interface", "Dest_IP", "Dest_Mac", "Hostname", "VLAN", "Family", "Attempts", "Vendor"}, ImportArp},
+		{"older arp export", []string{"Dest_IP", "Dest_Mac", "Hostname"}, ImportArp},
+		{"renamed column", []string{"Dest_IP", "Dest_Mac", "Hostname", "Retries"}, ImportArp},
+		{"nmap export", []string{"Port", "Protocol", "State", "Service", "Product"}, ImportNmap},
+		{"padded names", []string{" Port", "Protocol ", "State"}, ImportNmap},
+		{"unknown columns", []string{"Name", "Colour"}, ""},
+		{"mostly unknown", []string{"Port", "Colour", "Size", "Weight"}, ""},
+		{"no columns", nil, ""},
+	}
+	for _, tt := range tests {
+		target, err := matchImportTarget(tt.columns)
+		switch {
+		case tt.want == "" && err == nil:
+			t.Errorf("%s: matched %s, want an error", tt.name, target.kind)
+		case tt.want != "" && err != nil:
+			t.Errorf("%s: %v", tt.name, err)
+		case target.kind != tt.want:
+			t.Errorf("%s: matched %s, want %s", tt.name, target.kind, tt.want)
+		}
+	}
+}
+
+func TestDiffRows(t *testing.T) {
+	row := func(ip, mac string) ScanResultDfActive { return ScanResultDfActive{Dest_IP: ip, Dest_Mac: mac} }
+	tests := []struct {
+		name string
+		a, b []ScanResultDfActive
+		want []ScanResultDfActive
+	}{
+		{"same", []ScanResultDfActive{row("192.0.2.1", "a")}, []ScanResultDfActive{row("192.0.2.1", "a")}, nil},
+		{"new host", []ScanResultDfActive{row("192.0.2.1", "a")}, []ScanResultDfActive{row("192.0.2.1", "a"), row("192.0.2.2", "b")}, []ScanResultDfActive{row("192.0.2.2", "b")}},
+		{"changed mac", []ScanResultDfActive{row("192.0.2.1", "a")}, []ScanResultDfActive{row("192.0.2.1", "c")}, []ScanResultDfActive{row("192.0.2.1", "c")}},
+		{"duplicate", []ScanResultDfActive{row("192.0.2.1", "a")}, []ScanResultDfActive{row("192.0.2.1", "a"), row("192.0.2.1", "a")}, []ScanResultDfActive{row("192.0.2.1", "a")}},
+		{"gone", []ScanResultDfActive{row("192.0.2.1", "a")}, nil, nil},
+	}
+	for _, tt := range tests {
+		if got := diffRows(tt.a, tt.b); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: diffRows = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDiffImports(t *testing.T) {
+	saved := Defaultscan_results
+	t.Cleanup(func() { Defaultscan_results = saved })
+	Defaultscan_results = []ScanResultDfActive{{Dest_IP: "198.51.100.1"}}
+
+	dir := t.TempDir()
+	write := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	older := write("old.csv", "Interface,Dest_IP,Dest_Mac\neth0,192.0.2.1,00:00:5e:00:53:01\neth0,192.0.2.2,00:00:5e:00:53:02\n")
+	newer := write("new.json", `[{"Interface":"eth0","Dest_IP":"192.0.2.1","Dest_Mac":"00:00:5e:00:53:01"},{"Interface":"eth0","Dest_IP":"192.0.2.3","Dest_Mac":"00:00:5e:00:53:03"}]`)
+	nmapCSV := write("nmap.csv", "Port,Protocol,State\n22,tcp,open\n")
+
+	export := filepath.Join(dir, "changes.csv")
+	if err := DiffImports(older, newer, export); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path string
+		want string
+	}{
+		{filepath.Join(dir, "changes_added.csv"), "192.0.2.3"},
+		{filepath.Join(dir, "changes_removed.csv"), "192.0.2.2"},
+	}
+	for _, tt := range tests {
+		data, err := os.ReadFile(tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lines := strings.Split(strings.TrimSpace(string(data)), "\n"); len(lines) != 2 || !strings.Contains(lines[1], tt.want) {
+			t.Errorf("%s = %q, want one row with %s", tt.path, data, tt.want)
+		}
+	}
+	if len(Defaultscan_results) != 1 {
+		t.Errorf("diff left %d ARP results behind, want the 1 loaded before", len(Defaultscan_results))
+	}
+
+	if err := DiffImports(older, nmapCSV, ""); err == nil || !strings.Contains(err.Error(), "cannot compare arp results") {
+		t.Errorf("diff of different scan types: %v", err)
+	}
+}
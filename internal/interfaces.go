@@ -0,0 +1,110 @@
+package internal
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcap"
+)
+
+// libpcap interface flags (PCAP_IF_*)
+const (
+	pcapIfLoopback = 0x1
+	pcapIfUp       = 0x2
+)
+
+// seenInterfaces records the capture interfaces every source IP and MAC
+// address was seen on.
+var seenInterfaces = make(addressTags)
+
+// addressTags collects labels, such as interfaces or VLANs, per source IP and
+// MAC address.
+type addressTags map[string]map[string]struct{}
+
+// capturedPacket is a packet tagged with the interface it was captured on.
+type capturedPacket struct {
+	packet gopacket.Packet
+	iface  string
+}
+
+// CaptureInterfaces lists the interfaces used by --all-interfaces: every
+// capture device that is up, is not a loopback and is a real network
+// interface. Pseudo devices such as "any" or usbmon are skipped because they
+// have neither addresses nor an entry in the system interface list.
+func CaptureInterfaces() ([]string, error) {
+	devs, err := pcap.FindAllDevs()
+	if err != nil {
+		return nil, fmt.Errorf("pcap device enumeration failed: %w", err)
+	}
+	var names []string
+	for _, dev := range devs {
+		if dev.Flags&pcapIfUp == 0 || dev.Flags&pcapIfLoopback != 0 {
+			continue
+		}
+		if _, err := net.InterfaceByName(dev.Name); err != nil && len(dev.Addresses) == 0 {
+			continue
+		}
+		names = append(names, dev.Name)
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no capture interfaces are up")
+	}
+	return names, nil
+}
+
+// observeInterface records the interface the source of a packet was seen on.
+func observeInterface(packet gopacket.Packet, iface string) {
+	if iface != "" {
+		seenInterfaces.add(packet, iface)
+	}
+}
+
+// add tags the source IP and MAC address of a packet with tag.
+func (t addressTags) add(packet gopacket.Packet, tag string) {
+	var addrs []string
+	if ipLayer := packet.Layer(layers.LayerTypeIPv4); ipLayer != nil {
+		addrs = append(addrs, ipLayer.(*layers.IPv4).SrcIP.String())
+	} else if ipLayer := packet.Layer(layers.LayerTypeIPv6); ipLayer != nil {
+		addrs = append(addrs, ipLayer.(*layers.IPv6).SrcIP.String())
+	}
+	if mac := packetSourceMAC(packet); mac != "" {
+		addrs = append(addrs, mac)
+	}
+	for _, addr := range addrs {
+		set, ok := t[addr]
+		if !ok {
+			set = make(map[string]struct{})
+			t[addr] = set
+		}
+		set[tag] = struct{}{}
+	}
+}
+
+// lookup returns the tags of an asset. The IP address is preferred because a
+// router's MAC fronts every address behind it.
+func (t addressTags) lookup(ip string, mac string) string {
+	if set, ok := t[ip]; ok {
+		return joinSet(set)
+	}
+	return joinSet(t[mac])
+}
+
+// applyInterfaces tags the passive results with the interfaces and VLANs they
+// were seen on.
+func applyInterfaces() {
+	for i := range Passive_results {
+		r := &Passive_results[i]
+		r.Interface = seenInterfaces.lookup(r.SrcIP, r.SrcMAC)
+		r.VLAN = seenVLANs.lookup(r.SrcIP, r.SrcMAC)
+	}
+	for i := range PassiveHost_results {
+		r := &PassiveHost_results[i]
+		r.Interface = seenInterfaces.lookup(r.IP, r.MAC)
+		r.VLAN = seenVLANs.lookup(r.IP, r.MAC)
+	}
+	for i := range Neighbour_results {
+		Neighbour_results[i].Interface = joinSet(seenInterfaces[Neighbour_results[i].SrcMAC])
+	}
+}
@@ -0,0 +1,174 @@
+package internal
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+
+	"github.com/Naman1997/discovr/verbose"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// decodeNDP learns IPv6 addresses from neighbour discovery. NDP never crosses
+// a router, so unlike routed traffic the addresses it carries belong to the
+// device sending them. Link-local source addresses are on-link by definition
+// and are recorded from any packet.
+func decodeNDP(packet gopacket.Packet) {
+	ipLayer := packet.Layer(layers.LayerTypeIPv6)
+	if ipLayer == nil {
+		return
+	}
+	ip := ipLayer.(*layers.IPv6)
+	mac := packetSourceMAC(packet)
+	if !isUnicastMAC(mac) {
+		return
+	}
+
+	if ip.SrcIP.IsLinkLocalUnicast() {
+		addIPv6(packet, mac, ip.SrcIP, "IPv6")
+	}
+
+	if nsLayer := packet.Layer(layers.LayerTypeICMPv6NeighborSolicitation); nsLayer != nil {
+		ns := nsLayer.(*layers.ICMPv6NeighborSolicitation)
+		if ip.SrcIP.IsUnspecified() {
+			// duplicate address detection announces the address about to be used
+			addIPv6(packet, mac, ns.TargetAddress, "NDP")
+		} else if !ip.SrcIP.IsLinkLocalUnicast() {
+			addIPv6(packet, mac, ip.SrcIP, "NDP")
+		}
+	} else if naLayer := packet.Layer(layers.LayerTypeICMPv6NeighborAdvertisement); naLayer != nil {
+		na := naLayer.(*layers.ICMPv6NeighborAdvertisement)
+		if target := ndpLinkLayerAddress(na.Options, layers.ICMPv6OptTargetAddress); target != "" {
+			mac = target
+		}
+		addIPv6(packet, mac, na.TargetAddress, "NDP")
+	} else if raLayer := packet.Layer(layers.LayerTypeICMPv6RouterAdvertisement); raLayer != nil {
+		ra := raLayer.(*layers.ICMPv6RouterAdvertisement)
+		if source := ndpLinkLayerAddress(ra.Options, layers.ICMPv6OptSourceAddress); source != "" {
+			mac = source
+		}
+		d := addIPv6(packet, mac, ip.SrcIP, "NDP")
+		if d == nil {
+			return
+		}
+		if ra.RouterLifetime > 0 {
+			d.services["ipv6-router"] = struct{}{}
+		}
+		for _, prefix := range ndpPrefixes(ra.Options) {
+			d.services["ra-prefix:"+prefix] = struct{}{}
+		}
+		verbose.VerbosePrintf("Router advertisement from %s (%s): lifetime=%ds prefixes=%v\n",
+			ip.SrcIP, mac, ra.RouterLifetime, ndpPrefixes(ra.Options))
+	}
+}
+
+// addIPv6 records an IPv6 address of a device and returns its detail record.
+func addIPv6(packet gopacket.Packet, mac string, ip net.IP, protocol string) *hostDetail {
+	if ip == nil || ip.To4() != nil || ip.IsUnspecified() || ip.IsMulticast() || ip.IsLoopback() {
+		return nil
+	}
+	d := detailFor(mac)
+	if d.seen.IsZero() {
+		d.seen = packetTime(packet)
+		d.protocol = protocol
+	}
+	if _, ok := d.ipv6[ip.String()]; !ok {
+		d.ipv6[ip.String()] = struct{}{}
+		verbose.VerbosePrintf("IPv6 address %s at %s (privacy: %v)\n", ip, mac, isPrivacyAddress(ip, mac))
+	}
+	return d
+}
+
+// ndpLinkLayerAddress returns the MAC carried in a source or target
+// link-layer address option.
+func ndpLinkLayerAddress(options layers.ICMPv6Options, kind layers.ICMPv6Opt) string {
+	for _, opt := range options {
+		if opt.Type == kind && len(opt.Data) >= 6 {
+			return net.HardwareAddr(opt.Data[:6]).String()
+		}
+	}
+	return ""
+}
+
+// ndpPrefixes returns the on-link prefixes of a router advertisement (RFC 4861 4.6.2).
+func ndpPrefixes(options layers.ICMPv6Options) []string {
+	var prefixes []string
+	for _, opt := range options {
+		if opt.Type != layers.ICMPv6OptPrefixInfo || len(opt.Data) < 30 {
+			continue
+		}
+		length := int(opt.Data[0])
+		prefix := net.IP(opt.Data[14:30])
+		prefixes = append(prefixes, fmt.Sprintf("%s/%d", prefix, length))
+	}
+	return prefixes
+}
+
+// isPrivacyAddress reports whether a global IPv6 address uses a random
+// interface identifier (RFC 4941 temporary or RFC 7217 stable privacy address)
+// rather than the modified EUI-64 derived from the MAC address. Identifiers
+// with the upper 32 bits clear, such as the static ::10 or DHCPv6 ::1:5, are
+// assigned by hand or by a server, as are ones that embed an IPv4 address.
+func isPrivacyAddress(ip net.IP, mac string) bool {
+	if ip.To4() != nil || ip.IsLinkLocalUnicast() || !ip.IsGlobalUnicast() {
+		return false
+	}
+	hw, err := net.ParseMAC(mac)
+	if err != nil || len(hw) != 6 {
+		return false
+	}
+	iid := ip.To16()[8:]
+	upper := binary.BigEndian.Uint32(iid[:4])
+	switch {
+	case upper == 0:
+		return false
+	case iid[3] == 0xff && iid[4] == 0xfe:
+		// Modified EUI-64, of this MAC or another interface of the device
+		return false
+	case upper&^0x02000000 == 0x00005efe:
+		// ISATAP
+		return false
+	}
+	return true
+}
+
+// primaryIPv6 picks the address used to list a device seen only over IPv6,
+// preferring global addresses over link-local ones.
+func (d *hostDetail) primaryIPv6() string {
+	var linkLocal string
+	for _, addr := range sortedSet(d.ipv6) {
+		if !net.ParseIP(addr).IsLinkLocalUnicast() {
+			return addr
+		}
+		if linkLocal == "" {
+			linkLocal = addr
+		}
+	}
+	return linkLocal
+}
+
+// ipv6Columns splits the IPv6 addresses of a device into the link-local,
+// global and privacy result columns.
+func (d *hostDetail) ipv6Columns() map[string]string {
+	linkLocal := make(map[string]struct{})
+	global := make(map[string]struct{})
+	privacy := make(map[string]struct{})
+	for addr := range d.ipv6 {
+		ip := net.ParseIP(addr)
+		switch {
+		case ip.IsLinkLocalUnicast():
+			linkLocal[addr] = struct{}{}
+		case isPrivacyAddress(ip, d.mac):
+			global[addr] = struct{}{}
+			privacy[addr] = struct{}{}
+		default:
+			global[addr] = struct{}{}
+		}
+	}
+	return map[string]string{
+		"IPv6LinkLocal": joinSet(linkLocal),
+		"IPv6Global":    joinSet(global),
+		"IPv6Privacy":   joinSet(privacy),
+	}
+}
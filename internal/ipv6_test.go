@@ -0,0 +1,38 @@
+package internal
+
+import (
+	"net"
+	"testing"
+)
+
+func TestIsPrivacyAddress(t *testing.T) {
+	const mac = "00:50:56:12:34:56"
+	tests := []struct {
+		name string
+		ip   string
+		mac  string
+		want bool
+	}{
+		{"eui-64", "2001:db8::250:56ff:fe12:3456", mac, false},
+		{"eui-64 of another interface", "2001:db8::250:56ff:fe99:9999", mac, false},
+		{"static", "2001:db8::10", mac, false},
+		{"static ::1", "2001:db8::1", mac, false},
+		{"dhcpv6", "2001:db8::1:5", mac, false},
+		{"dhcpv6 pool", "2001:db8:0:1::1000:25", mac, false},
+		{"embedded ipv4", "2001:db8::192.0.2.5", mac, false},
+		{"isatap", "2001:db8::5efe:c000:205", mac, false},
+		{"isatap global", "2001:db8::200:5efe:c000:205", mac, false},
+		{"temporary", "2001:db8::8d2c:41a7:93e0:1b5f", mac, true},
+		{"stable privacy", "2001:db8:0:1:a1b2:c3d4:e5f6:789", mac, true},
+		{"ula random", "fd00::3c5e:9a01:77d2:4e1b", mac, true},
+		{"link-local random", "fe80::8d2c:41a7:93e0:1b5f", mac, false},
+		{"multicast", "ff02::1:ff12:3456", mac, false},
+		{"ipv4", "192.0.2.5", mac, false},
+		{"bad mac", "2001:db8::8d2c:41a7:93e0:1b5f", "not a mac", false},
+	}
+	for _, tt := range tests {
+		if got := isPrivacyAddress(net.ParseIP(tt.ip), tt.mac); got != tt.want {
+			t.Errorf("%s: isPrivacyAddress(%s) = %v, want %v", tt.name, tt.ip, got, tt.want)
+		}
+	}
+}
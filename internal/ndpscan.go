@@ -0,0 +1,276 @@
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Naman1997/discovr/verbose"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcap"
+)
+
+// maxNDPTargetBits caps the size of an IPv6 --cidr: a /112 is the largest range
+// that is solicited address by address.
+const maxNDPTargetBits = 16
+
+// linkLocalPrefix is the prefix every link-local address is formed in, see
+// RFC 4291 section 2.5.6.
+const linkLocalPrefix = "fe80::/64"
+
+var allNodes = net.ParseIP("ff02::1")
+
+// NDPScan discovers IPv6 hosts on the link of iface. An ICMPv6 echo request to
+// the all-nodes group ff02::1 is answered by every IPv6 host, from its
+// link-local address and from each global one the echo was sent from. Neighbor
+// Solicitations then confirm the EUI-64 addresses the responders would form in
+// the prefixes of the interface, plus every IPv6 target in opts if given.
+// Echo replies and Neighbor Advertisements both map addresses to MACs.
+// Multicast reaches hosts that were never asked for, so responders that are
+// excluded or outside opts.Scope are not recorded.
+func NDPScan(iface *net.Interface, opts ActiveOptions) {
+	verbose.VerbosePrintln("Starting IPv6 neighbor discovery scan...")
+	devices, err := pcap.FindAllDevs()
+	if err != nil {
+		panic(err)
+	}
+	if err := scanNDP(iface, &devices, opts); err != nil {
+		verbose.Printf("interface %v: %v\n", iface.Name, err)
+	}
+}
+
+func scanNDP(iface *net.Interface, devices *[]pcap.Interface, opts ActiveOptions) error {
+	linkLocal, globals := interfaceIPv6(iface)
+	if linkLocal == nil {
+		return errors.New("no IPv6 link-local address found")
+	}
+
+	var targets []net.IP
+	if opts.Targets != nil {
+		requested := opts.Targets.IPv6().Subtract(opts.Exclude)
+		if requested.Empty() {
+			return verbose.VerboseErrorf("no IPv6 targets left to solicit")
+		}
+		if requested.Count() > 1<<maxNDPTargetBits {
+			return verbose.VerboseErrorf("IPv6 targets cover more than %d addresses, use a /%d or smaller range", 1<<maxNDPTargetBits, 128-maxNDPTargetBits)
+		}
+		targets = slices.Collect(requested.Addrs())
+	}
+
+	deviceName := pcapDeviceName(devices, linkLocal)
+	if deviceName == "" {
+		deviceName = iface.Name
+	}
+	handle, err := pcap.OpenLive(deviceName, 65536, true, pcap.BlockForever)
+	if err != nil {
+		return err
+	}
+	defer handle.Close()
+	if err := handle.SetBPFFilter("icmp6"); err != nil {
+		return err
+	}
+
+	// Responders are collected so their EUI-64 addresses can be solicited
+	var macsMu sync.Mutex
+	macs := make(map[string]net.HardwareAddr)
+	stop := make(chan struct{})
+	go readNDP(handle, iface, opts, stop, func(mac net.HardwareAddr) {
+		macsMu.Lock()
+		macs[mac.String()] = mac
+		macsMu.Unlock()
+	})
+	defer close(stop)
+
+	sources := append([]net.IP{linkLocal}, ipNetIPs(globals)...)
+	for _, src := range sources {
+		if err := writeICMPv6(handle, iface, src, allNodes, layers.ICMPv6TypeEchoRequest,
+			&layers.ICMPv6Echo{Identifier: uint16(os.Getpid()), SeqNumber: 1}); err != nil {
+			return err
+		}
+	}
+	time.Sleep(time.Second)
+
+	macsMu.Lock()
+	for _, prefix := range globals {
+		for _, mac := range macs {
+			if addr := eui64Address(prefix, mac); addr != nil && ndpAllowed(addr, opts) {
+				targets = append(targets, addr)
+			}
+		}
+	}
+	macsMu.Unlock()
+
+	verbose.VerbosePrintf("Soliciting %d IPv6 addresses on interface %v\n", len(targets), iface.Name)
+	for _, target := range targets {
+		src := linkLocal
+		for _, global := range globals {
+			if global.Contains(target) {
+				src = global.IP
+			}
+		}
+		ns := &layers.ICMPv6NeighborSolicitation{
+			TargetAddress: target,
+			Options:       layers.ICMPv6Options{{Type: layers.ICMPv6OptSourceAddress, Data: iface.HardwareAddr}},
+		}
+		if err := writeICMPv6(handle, iface, src, solicitedNodeAddress(target), layers.ICMPv6TypeNeighborSolicitation, ns); err != nil {
+			return err
+		}
+	}
+
+	time.Sleep(3 * time.Second)
+	return nil
+}
+
+// ndpAllowed reports whether an IPv6 address may be solicited or recorded.
+func ndpAllowed(addr net.IP, opts ActiveOptions) bool {
+	return !opts.Exclude.Contains(addr) && opts.Scope.Allows(addr)
+}
+
+// readNDP reads echo replies and Neighbor Advertisements from the handle and
+// records the address and MAC of every allowed responder.
+func readNDP(handle *pcap.Handle, iface *net.Interface, opts ActiveOptions, stop chan struct{}, responder func(net.HardwareAddr)) {
+	src := gopacket.NewPacketSource(handle, layers.LayerTypeEthernet)
+	in := src.Packets()
+
+	for {
+		var packet gopacket.Packet
+		select {
+		case <-stop:
+			return
+		case packet = <-in:
+			ethLayer := packet.Layer(layers.LayerTypeEthernet)
+			ipLayer := packet.Layer(layers.LayerTypeIPv6)
+			if ethLayer == nil || ipLayer == nil {
+				continue
+			}
+			eth := ethLayer.(*layers.Ethernet)
+			ip := ipLayer.(*layers.IPv6)
+			if bytes.Equal(iface.HardwareAddr, eth.SrcMAC) {
+				continue
+			}
+
+			var addr net.IP
+			mac := eth.SrcMAC
+			if packet.Layer(layers.LayerTypeICMPv6Echo) != nil {
+				icmp, _ := packet.Layer(layers.LayerTypeICMPv6).(*layers.ICMPv6)
+				if icmp == nil || icmp.TypeCode.Type() != layers.ICMPv6TypeEchoReply {
+					continue
+				}
+				addr = ip.SrcIP
+			} else if naLayer := packet.Layer(layers.LayerTypeICMPv6NeighborAdvertisement); naLayer != nil {
+				na := naLayer.(*layers.ICMPv6NeighborAdvertisement)
+				if target := ndpLinkLayerAddress(na.Options, layers.ICMPv6OptTargetAddress); target != "" {
+					mac, _ = net.ParseMAC(target)
+				}
+				addr = na.TargetAddress
+			} else {
+				continue
+			}
+
+			// The MAC is still used to solicit the responder's allowed EUI-64 addresses
+			responder(mac)
+			if !ndpAllowed(addr, opts) {
+				verbose.VerbosePrintf("Ignoring reply from %v, excluded or out of scope\n", addr)
+				continue
+			}
+			addDefaultResult(ScanResultDfActive{
+				Interface: iface.Name,
+				Dest_IP:   addr.String(),
+				Dest_Mac:  mac.String(),
+				Family:    "IPv6",
+				Attempts:  1,
+			})
+		}
+	}
+}
+
+// writeICMPv6 sends an ICMPv6 message from src to a multicast group.
+func writeICMPv6(handle *pcap.Handle, iface *net.Interface, src net.IP, dst net.IP, typ uint8, body gopacket.SerializableLayer) error {
+	eth := layers.Ethernet{
+		SrcMAC:       iface.HardwareAddr,
+		DstMAC:       multicastMAC(dst),
+		EthernetType: layers.EthernetTypeIPv6,
+	}
+	ip := layers.IPv6{
+		Version:    6,
+		HopLimit:   255,
+		NextHeader: layers.IPProtocolICMPv6,
+		SrcIP:      src,
+		DstIP:      dst,
+	}
+	icmp := layers.ICMPv6{TypeCode: layers.CreateICMPv6TypeCode(typ, 0)}
+	if err := icmp.SetNetworkLayerForChecksum(&ip); err != nil {
+		return err
+	}
+
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{
+		FixLengths:       true,
+		ComputeChecksums: true,
+	}
+	if err := gopacket.SerializeLayers(buf, opts, &eth, &ip, &icmp, body); err != nil {
+		return err
+	}
+	return handle.WritePacketData(buf.Bytes())
+}
+
+// interfaceIPv6 returns the link-local address and the global networks of iface.
+func interfaceIPv6(iface *net.Interface) (net.IP, []*net.IPNet) {
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, nil
+	}
+	var linkLocal net.IP
+	var globals []*net.IPNet
+	for _, a := range addrs {
+		ipnet, ok := a.(*net.IPNet)
+		if !ok || ipnet.IP.To4() != nil {
+			continue
+		}
+		if ipnet.IP.IsLinkLocalUnicast() {
+			if linkLocal == nil {
+				linkLocal = ipnet.IP
+			}
+		} else if ipnet.IP.IsGlobalUnicast() {
+			globals = append(globals, ipnet)
+		}
+	}
+	return linkLocal, globals
+}
+
+func ipNetIPs(nets []*net.IPNet) []net.IP {
+	var out []net.IP
+	for _, n := range nets {
+		out = append(out, n.IP)
+	}
+	return out
+}
+
+// eui64Address forms the SLAAC address a MAC would use in a /64 prefix.
+func eui64Address(prefix *net.IPNet, mac net.HardwareAddr) net.IP {
+	if ones, _ := prefix.Mask.Size(); ones != 64 || len(mac) != 6 {
+		return nil
+	}
+	addr := make(net.IP, net.IPv6len)
+	copy(addr, prefix.IP.Mask(prefix.Mask))
+	copy(addr[8:], []byte{mac[0] ^ 0x02, mac[1], mac[2], 0xff, 0xfe, mac[3], mac[4], mac[5]})
+	return addr
+}
+
+// solicitedNodeAddress returns the ff02::1:ffXX:XXXX group of an address (RFC 4291 2.7.1).
+func solicitedNodeAddress(ip net.IP) net.IP {
+	addr := net.ParseIP("ff02::1:ff00:0")
+	copy(addr[13:], ip.To16()[13:])
+	return addr
+}
+
+// multicastMAC maps an IPv6 multicast group to its 33:33:xx:xx:xx:xx MAC (RFC 2464 7).
+func multicastMAC(ip net.IP) net.HardwareAddr {
+	ip = ip.To16()
+	return net.HardwareAddr{0x33, 0x33, ip[12], ip[13], ip[14], ip[15]}
+}
@@ -0,0 +1,153 @@
+package internal
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Naman1997/discovr/verbose"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+var (
+	Neighbour_results []ScanResultNeighbour
+	seenNeighbours    = make(map[string]int)
+)
+
+// ScanResultNeighbour is a switch, router or other device announcing itself
+// with LLDP or CDP on the link the scanner is attached to.
+type ScanResultNeighbour struct {
+	Protocol     string
+	ChassisID    string
+	PortID       string
+	SystemName   string
+	Description  string
+	MgmtAddress  string
+	VLAN         string
+	Capabilities string
+	SrcMAC       string
+	LastSeen     string
+	Interface    string
+}
+
+// decodeNeighbours records LLDP and CDP frames. Neighbours re-announce every
+// 30-60 seconds, so repeated frames refresh the existing entry.
+func decodeNeighbours(packet gopacket.Packet) {
+	var result ScanResultNeighbour
+	if lldpLayer := packet.Layer(layers.LayerTypeLinkLayerDiscovery); lldpLayer != nil {
+		result = lldpNeighbour(lldpLayer.(*layers.LinkLayerDiscovery), packet.Layer(layers.LayerTypeLinkLayerDiscoveryInfo))
+	} else if cdpLayer := packet.Layer(layers.LayerTypeCiscoDiscoveryInfo); cdpLayer != nil {
+		result = cdpNeighbour(cdpLayer.(*layers.CiscoDiscoveryInfo))
+	} else {
+		return
+	}
+	result.SrcMAC = packetSourceMAC(packet)
+	result.LastSeen = packetTime(packet).Format(time.RFC3339)
+
+	key := result.Protocol + "_" + result.ChassisID + "_" + result.PortID
+	if i, ok := seenNeighbours[key]; ok {
+		Neighbour_results[i] = result
+		return
+	}
+	seenNeighbours[key] = len(Neighbour_results)
+	Neighbour_results = append(Neighbour_results, result)
+	verbose.VerbosePrintf("%s neighbour %s (%s) on port %s, management address %s\n",
+		result.Protocol, result.SystemName, result.ChassisID, result.PortID, result.MgmtAddress)
+}
+
+func lldpNeighbour(lldp *layers.LinkLayerDiscovery, infoLayer gopacket.Layer) ScanResultNeighbour {
+	result := ScanResultNeighbour{
+		Protocol:  "LLDP",
+		ChassisID: lldpID(byte(lldp.ChassisID.Subtype), lldp.ChassisID.ID, byte(layers.LLDPChassisIDSubTypeMACAddr), byte(layers.LLDPChassisIDSubTypeNetworkAddr)),
+		PortID:    lldpID(byte(lldp.PortID.Subtype), lldp.PortID.ID, byte(layers.LLDPPortIDSubtypeMACAddr), byte(layers.LLDPPortIDSubtypeNetworkAddr)),
+	}
+	if infoLayer == nil {
+		return result
+	}
+	info := infoLayer.(*layers.LinkLayerDiscoveryInfo)
+	result.SystemName = info.SysName
+	result.Description = firstLine(info.SysDescription)
+	if info.PortDescription != "" {
+		result.PortID += " (" + info.PortDescription + ")"
+	}
+	switch info.MgmtAddress.Subtype {
+	case layers.IANAAddressFamilyIPV4, layers.IANAAddressFamilyIPV6:
+		result.MgmtAddress = net.IP(info.MgmtAddress.Address).String()
+	case layers.IANAAddressFamily802:
+		result.MgmtAddress = net.HardwareAddr(info.MgmtAddress.Address).String()
+	}
+	if dot1, err := info.Decode8021(); err == nil && dot1.PVID != 0 {
+		result.VLAN = strconv.Itoa(int(dot1.PVID))
+	}
+
+	c := info.SysCapabilities.EnabledCap
+	result.Capabilities = capabilityList(map[string]bool{
+		"Router": c.Router, "Bridge": c.Bridge, "WLAN": c.WLANAP, "Phone": c.Phone,
+		"Repeater": c.Repeater, "DOCSIS": c.DocSis, "Station": c.StationOnly, "Other": c.Other,
+	})
+	return result
+}
+
+// lldpID renders a chassis or port ID according to its subtype: MAC and
+// network addresses are binary, the remaining subtypes are text.
+func lldpID(subtype byte, id []byte, macSubtype byte, netSubtype byte) string {
+	switch subtype {
+	case macSubtype:
+		return net.HardwareAddr(id).String()
+	case netSubtype:
+		// first byte is the IANA address family
+		if len(id) > 1 {
+			return net.IP(id[1:]).String()
+		}
+	}
+	return string(id)
+}
+
+func cdpNeighbour(cdp *layers.CiscoDiscoveryInfo) ScanResultNeighbour {
+	result := ScanResultNeighbour{
+		Protocol:    "CDP",
+		ChassisID:   cdp.DeviceID,
+		PortID:      cdp.PortID,
+		SystemName:  cdp.SysName,
+		Description: strings.TrimSpace(cdp.Platform + " " + firstLine(cdp.Version)),
+	}
+	if result.SystemName == "" {
+		result.SystemName = cdp.DeviceID
+	}
+	addrs := cdp.MgmtAddresses
+	if len(addrs) == 0 {
+		addrs = cdp.Addresses
+	}
+	var mgmt []string
+	for _, a := range addrs {
+		mgmt = append(mgmt, a.String())
+	}
+	result.MgmtAddress = strings.Join(mgmt, " ")
+	if cdp.NativeVLAN != 0 {
+		result.VLAN = strconv.Itoa(int(cdp.NativeVLAN))
+	}
+
+	c := cdp.Capabilities
+	result.Capabilities = capabilityList(map[string]bool{
+		"Router": c.L3Router, "Bridge": c.TBBridge || c.SPBridge, "Switch": c.L2Switch, "Host": c.IsHost,
+		"Phone": c.IsPhone, "Repeater": c.L1Repeater, "IGMP": c.IGMPFilter, "Remote": c.RemotelyManaged,
+	})
+	return result
+}
+
+func capabilityList(caps map[string]bool) string {
+	set := make(map[string]struct{})
+	for name, enabled := range caps {
+		if enabled {
+			set[name] = struct{}{}
+		}
+	}
+	return joinSet(set)
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+	return line
+}
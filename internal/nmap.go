@@ -29,7 +29,7 @@ type ScanResultActive struct {
 	Product  string
 }
 
-func NmapScan(targets string, ports string, osDetection bool) {
+func NmapScan(targets []string, ports string, osDetection bool) {
 
 	//TODO: Scanning default scan if not using nmap
 
@@ -40,6 +40,11 @@ func NmapScan(targets string, ports string, osDetection bool) {
 	// 	// extract nmap
 	// }
 
+	// Only enable OS detection if user is running with elevated privs
+	if osDetection && !isElevated() {
+		verbose.VerboseFatalfMsg("Scan Failed: OS scan requires elevated privileges!")
+	}
+
 	nmapDir, nmapPath := extractNmap()
 
 	// Keeping this around for debugging
@@ -47,31 +52,9 @@ func NmapScan(targets string, ports string, osDetection bool) {
 	// fmt.Println("")
 
 	// Configure the nmap scanner
-	scanner, err := createScanner(targets, nmapPath)
-	if ports == "" {
-		scanner.AddOptions(nmap.WithMostCommonPorts(1000))
-	} else {
-		scanner.AddOptions(nmap.WithPorts(ports))
-	}
-
-	// Only enable OS detection if user is running with elevated privs
-	if osDetection {
-		isAdmin := false
-		if runtime.GOOS == "windows" {
-			isAdmin = isWindowsAdmin()
-		} else {
-			// For Unix-like systems (Linux, macOS, etc.)
-			if os.Geteuid() == 0 {
-				isAdmin = true
-			}
-		}
-
-		if isAdmin {
-			scanner.AddOptions(nmap.WithOSDetection())
-			scanner.AddOptions(nmap.WithPrivileged())
-		} else {
-			verbose.VerboseFatalfMsg("Scan Failed: OS scan requires elevated privileges!")
-		}
+	scanner, err := createScanner(targets, nmapPath, ports, osDetection)
+	if err != nil {
+		verbose.VerboseFatalfMsg("nmap scan failed: %v", err)
 	}
 
 	result, warnings, err := scanner.Run()
@@ -82,7 +65,17 @@ func NmapScan(targets string, ports string, osDetection bool) {
 		verbose.VerboseFatalfMsg("nmap scan failed: %v", err)
 	}
 
-	// TODO: Wait for SRUM-8 and implement the method to export this information to a csv file
+	collectNmapResults(result)
+
+	verbose.VerbosePrintf("Nmap done: %d hosts up scanned in %.2f seconds\n", len(result.Hosts), result.Stats.Finished.Elapsed)
+
+	// Remove the dir containing nmap
+	defer os.RemoveAll(nmapDir)
+}
+
+// collectNmapResults appends the open ports of every host in an nmap run to
+// Active_results. It is shared by live scans and imported nmap XML reports.
+func collectNmapResults(result *nmap.Run) {
 	// Use the results to get the OS and ports open
 	for _, host := range result.Hosts {
 		if len(host.Ports) == 0 || len(host.Addresses) == 0 {
@@ -124,21 +117,45 @@ func NmapScan(targets string, ports string, osDetection bool) {
 			Active_results = append(Active_results, result)
 		}
 	}
-
-	verbose.VerbosePrintf("Nmap done: %d hosts up scanned in %.2f seconds\n", len(result.Hosts), result.Stats.Finished.Elapsed)
-
-	// Remove the dir containing nmap
-	defer os.RemoveAll(nmapDir)
 }
 
-func createScanner(targets string, nmapPath string) (*nmap.Scanner, error) {
-	return nmap.NewScanner(
+func createScanner(targets []string, nmapPath string, ports string, osDetection bool) (*nmap.Scanner, error) {
+	scanner, err := nmap.NewScanner(
 		context.Background(),
-		nmap.WithTargets(targets),
+		nmap.WithTargets(targets...),
 		nmap.WithBinaryPath(nmapPath),
 		nmap.WithServiceInfo(),
 		nmap.WithUnprivileged(),
 	)
+	if err != nil {
+		return nil, err
+	}
+	if ports == "" {
+		scanner.AddOptions(nmap.WithMostCommonPorts(1000))
+	} else {
+		scanner.AddOptions(nmap.WithPorts(ports))
+	}
+	if osDetection {
+		scanner.AddOptions(nmap.WithOSDetection())
+		scanner.AddOptions(nmap.WithPrivileged())
+	}
+	return scanner, nil
+}
+
+// NmapCommandLine returns the nmap command NmapScan would run, with the
+// temporary directory the embedded nmap is extracted to left as a pattern.
+func NmapCommandLine(targets []string, ports string, osDetection bool) (string, error) {
+	binary := filepath.Join(os.TempDir(), "discovr-embedded-bin-*", "nmap-"+NmapVersion, "nmap")
+	if runtime.GOOS == "windows" {
+		binary += ".exe"
+	}
+	scanner, err := createScanner(targets, binary, ports, osDetection)
+	if err != nil {
+		return "", err
+	}
+	// Run adds the XML output to stdout
+	args := append([]string{binary}, scanner.Args()...)
+	return strings.Join(append(args, "-oX", "-"), " "), nil
 }
 
 func extractNmap() (string, string) {
@@ -210,6 +227,15 @@ func unzip(destination string, zipFilePath string) {
 	}
 }
 
+// isElevated reports whether discovr runs as root or as a Windows administrator.
+func isElevated() bool {
+	if runtime.GOOS == "windows" {
+		return isWindowsAdmin()
+	}
+	// For Unix-like systems (Linux, macOS, etc.)
+	return os.Geteuid() == 0
+}
+
 // Source: https://gist.github.com/jerblack/d0eb182cc5a1c1d92d92a4c4fcc416c6
 func isWindowsAdmin() bool {
 	_, err := os.Open("\\\\.\\PHYSICALDRIVE0")
@@ -0,0 +1,453 @@
+package internal
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Naman1997/discovr/verbose"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// p0fSignatureData holds TCP SYN and SYN+ACK signatures in p0f v3 format.
+//
+//go:embed data/p0f.fp
+var p0fSignatureData string
+
+var synSignatures, synAckSignatures = loadTCPSignatures(p0fSignatureData)
+
+// osDetails holds the OS guess of each host, keyed by IP address like tlsDetails.
+var osDetails = make(map[string]*osDetail)
+
+type osDetail struct {
+	guess    string
+	distance int
+	exact    bool
+}
+
+// rank orders guesses so a later exact match replaces a fuzzy one.
+func (d *osDetail) rank() int {
+	switch {
+	case d.guess == "":
+		return 0
+	case !d.exact:
+		return 1
+	}
+	return 2
+}
+
+// maxTTLDistance is how many hops below its initial TTL a packet may arrive
+// and still match a signature.
+const maxTTLDistance = 35
+
+// tcpSignature is one sig line of the p0f database. Numeric fields set to -1
+// match any value.
+type tcpSignature struct {
+	label   string
+	generic bool
+	version int
+	ittl    int
+	badTTL  bool
+	olen    int
+	mss     int
+	wsize   string
+	scale   int
+	olayout string
+	quirks  []string
+	pclass  string
+}
+
+// tcpObservation is the same set of characteristics read from a packet.
+type tcpObservation struct {
+	version int
+	ttl     int
+	olen    int
+	mss     int
+	wsize   int
+	scale   int
+	olayout string
+	quirks  []string
+	payload bool
+}
+
+// loadTCPSignatures parses the [tcp:request] and [tcp:response] sections of a
+// p0f v3 database. Other sections and sys lines are ignored.
+func loadTCPSignatures(data string) ([]tcpSignature, []tcpSignature) {
+	var request, response []tcpSignature
+	var section *[]tcpSignature
+	var label string
+	var generic bool
+
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, ";") {
+			continue
+		}
+		switch line {
+		case "[tcp:request]":
+			section = &request
+			continue
+		case "[tcp:response]":
+			section = &response
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			section = nil
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || section == nil {
+			continue
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		switch key {
+		case "label":
+			label, generic = formatP0fLabel(value)
+		case "sig":
+			sig, err := parseTCPSignature(value)
+			if err != nil {
+				panic(fmt.Sprintf("p0f.fp line %d: %v", lineNo, err))
+			}
+			sig.label, sig.generic = label, generic
+			*section = append(*section, sig)
+		}
+	}
+	return request, response
+}
+
+// formatP0fLabel turns s:unix:Linux:3.11 and newer into "Linux 3.11 and newer"
+// and reports whether the label is generic.
+func formatP0fLabel(label string) (string, bool) {
+	parts := strings.SplitN(label, ":", 4)
+	if len(parts) != 4 {
+		return label, false
+	}
+	return strings.TrimSpace(parts[2] + " " + parts[3]), parts[0] == "g"
+}
+
+func parseTCPSignature(value string) (tcpSignature, error) {
+	fields := strings.Split(value, ":")
+	if len(fields) != 8 {
+		return tcpSignature{}, fmt.Errorf("expected 8 fields in %q", value)
+	}
+	sig := tcpSignature{olayout: fields[5], pclass: fields[7]}
+	var err error
+	if sig.version, err = signatureInt(fields[0]); err != nil {
+		return sig, err
+	}
+	ittl := fields[1]
+	if strings.HasSuffix(ittl, "-") {
+		sig.badTTL = true
+		ittl = strings.TrimSuffix(ittl, "-")
+	}
+	if sig.ittl, err = strconv.Atoi(ittl); err != nil {
+		return sig, err
+	}
+	if sig.olen, err = strconv.Atoi(fields[2]); err != nil {
+		return sig, err
+	}
+	if sig.mss, err = signatureInt(fields[3]); err != nil {
+		return sig, err
+	}
+	wsize, scale, ok := strings.Cut(fields[4], ",")
+	if !ok {
+		return sig, fmt.Errorf("missing window scale in %q", fields[4])
+	}
+	sig.wsize = wsize
+	if sig.scale, err = signatureInt(scale); err != nil {
+		return sig, err
+	}
+	if fields[6] != "" {
+		sig.quirks = strings.Split(fields[6], ",")
+		slices.Sort(sig.quirks)
+	}
+	return sig, nil
+}
+
+func signatureInt(field string) (int, error) {
+	if field == "*" {
+		return -1, nil
+	}
+	return strconv.Atoi(field)
+}
+
+// matches compares an observation with the signature. Fuzzy matching ignores
+// quirks, which middleboxes and NAT often rewrite.
+func (sig *tcpSignature) matches(obs *tcpObservation, fuzzy bool) bool {
+	if sig.version != -1 && sig.version != obs.version {
+		return false
+	}
+	if obs.ttl > sig.ittl || sig.ittl-obs.ttl > maxTTLDistance {
+		return false
+	}
+	if sig.olen != obs.olen || sig.olayout != obs.olayout {
+		return false
+	}
+	if sig.mss != -1 && sig.mss != obs.mss {
+		return false
+	}
+	if sig.scale != -1 && sig.scale != obs.scale {
+		return false
+	}
+	if !sig.windowMatches(obs) {
+		return false
+	}
+	switch sig.pclass {
+	case "0":
+		if obs.payload {
+			return false
+		}
+	case "+":
+		if !obs.payload {
+			return false
+		}
+	}
+	return fuzzy || slices.Equal(sig.quirks, obs.quirks)
+}
+
+func (sig *tcpSignature) windowMatches(obs *tcpObservation) bool {
+	switch {
+	case sig.wsize == "*":
+		return true
+	case strings.HasPrefix(sig.wsize, "mss*"):
+		n, err := strconv.Atoi(strings.TrimPrefix(sig.wsize, "mss*"))
+		return err == nil && obs.mss > 0 && obs.wsize == obs.mss*n
+	case strings.HasPrefix(sig.wsize, "mtu*"):
+		n, err := strconv.Atoi(strings.TrimPrefix(sig.wsize, "mtu*"))
+		headers := 40
+		if obs.version == 6 {
+			headers = 60
+		}
+		return err == nil && obs.mss > 0 && obs.wsize == (obs.mss+headers)*n
+	case strings.HasPrefix(sig.wsize, "%"):
+		n, err := strconv.Atoi(strings.TrimPrefix(sig.wsize, "%"))
+		return err == nil && n > 0 && obs.wsize%n == 0
+	}
+	n, err := strconv.Atoi(sig.wsize)
+	return err == nil && obs.wsize == n
+}
+
+// matchTCPSignature looks for an exact specific match, then an exact generic
+// match, then the same again ignoring quirks.
+func matchTCPSignature(sigs []tcpSignature, obs *tcpObservation) (*tcpSignature, bool) {
+	for _, fuzzy := range []bool{false, true} {
+		var generic *tcpSignature
+		for i := range sigs {
+			if !sigs[i].matches(obs, fuzzy) {
+				continue
+			}
+			if !sigs[i].generic {
+				return &sigs[i], !fuzzy
+			}
+			if generic == nil {
+				generic = &sigs[i]
+			}
+		}
+		if generic != nil {
+			return generic, !fuzzy
+		}
+	}
+	return nil, false
+}
+
+// decodeTCPFingerprint fingerprints the TCP stack of hosts sending SYN and
+// SYN+ACK packets, which carry the most implementation specific options.
+func decodeTCPFingerprint(packet gopacket.Packet) {
+	tcpLayer := packet.Layer(layers.LayerTypeTCP)
+	if tcpLayer == nil {
+		return
+	}
+	tcp := tcpLayer.(*layers.TCP)
+	if !tcp.SYN || tcp.RST || tcp.FIN {
+		return
+	}
+
+	var obs tcpObservation
+	var srcIP net.IP
+	if ipLayer := packet.Layer(layers.LayerTypeIPv4); ipLayer != nil {
+		ip := ipLayer.(*layers.IPv4)
+		srcIP = ip.SrcIP
+		obs.version = 4
+		obs.ttl = int(ip.TTL)
+		obs.olen = int(ip.IHL)*4 - 20
+		df := ip.Flags&layers.IPv4DontFragment != 0
+		if df {
+			obs.quirks = append(obs.quirks, "df")
+		}
+		if df && ip.Id != 0 {
+			obs.quirks = append(obs.quirks, "id+")
+		}
+		if !df && ip.Id == 0 {
+			obs.quirks = append(obs.quirks, "id-")
+		}
+		if ip.TOS&0x03 != 0 {
+			obs.quirks = append(obs.quirks, "ecn")
+		}
+	} else if ipLayer := packet.Layer(layers.LayerTypeIPv6); ipLayer != nil {
+		ip := ipLayer.(*layers.IPv6)
+		srcIP = ip.SrcIP
+		obs.version = 6
+		obs.ttl = int(ip.HopLimit)
+		if ip.FlowLabel != 0 {
+			obs.quirks = append(obs.quirks, "flow")
+		}
+		if ip.TrafficClass&0x03 != 0 {
+			obs.quirks = append(obs.quirks, "ecn")
+		}
+	} else {
+		return
+	}
+
+	obs.wsize = int(tcp.Window)
+	obs.payload = len(tcp.Payload) > 0
+	obs.quirks = append(obs.quirks, tcpQuirks(tcp)...)
+	obs.olayout, obs.mss, obs.scale = tcpOptionLayout(tcp, &obs.quirks)
+	slices.Sort(obs.quirks)
+	obs.quirks = slices.Compact(obs.quirks)
+
+	sigs, kind := synSignatures, "SYN"
+	if tcp.ACK {
+		sigs, kind = synAckSignatures, "SYN+ACK"
+	}
+	sig, exact := matchTCPSignature(sigs, &obs)
+
+	d := &osDetail{distance: guessInitialTTL(obs.ttl) - obs.ttl}
+	if sig != nil {
+		d.guess, d.exact = sig.label, exact
+		if !sig.badTTL {
+			d.distance = sig.ittl - obs.ttl
+		}
+	}
+	if old, ok := osDetails[srcIP.String()]; ok && old.rank() >= d.rank() {
+		return
+	}
+	osDetails[srcIP.String()] = d
+
+	raw := fmt.Sprintf("%d:%d:%d:%d:%d,%d:%s:%s:%s", obs.version, obs.ttl, obs.olen, obs.mss, obs.wsize, obs.scale,
+		obs.olayout, strings.Join(obs.quirks, ","), map[bool]string{false: "0", true: "+"}[obs.payload])
+	verbose.VerbosePrintf("TCP %s from %s: sig=%s guess=%q exact=%v distance=%d\n", kind, srcIP, raw, d.guess, d.exact, d.distance)
+}
+
+// tcpQuirks returns the TCP header quirks of p0f that do not depend on options.
+func tcpQuirks(tcp *layers.TCP) []string {
+	var quirks []string
+	if tcp.ECE || tcp.CWR || tcp.NS {
+		quirks = append(quirks, "ecn")
+	}
+	if tcp.Seq == 0 {
+		quirks = append(quirks, "seq-")
+	}
+	if tcp.ACK && tcp.Ack == 0 {
+		quirks = append(quirks, "ack-")
+	}
+	if !tcp.ACK && tcp.Ack != 0 {
+		quirks = append(quirks, "ack+")
+	}
+	if tcp.URG {
+		quirks = append(quirks, "urgf+")
+	} else if tcp.Urgent != 0 {
+		quirks = append(quirks, "uptr+")
+	}
+	if tcp.PSH {
+		quirks = append(quirks, "pushf+")
+	}
+	return quirks
+}
+
+// tcpOptionLayout renders the option order in p0f notation and returns the
+// MSS and window scale, adding option related quirks as it goes.
+func tcpOptionLayout(tcp *layers.TCP, quirks *[]string) (string, int, int) {
+	var layout []string
+	mss, scale := 0, 0
+	for _, opt := range tcp.Options {
+		switch opt.OptionType {
+		case layers.TCPOptionKindEndList:
+			layout = append(layout, fmt.Sprintf("eol+%d", len(tcp.Padding)))
+			if slices.ContainsFunc(tcp.Padding, func(b byte) bool { return b != 0 }) {
+				*quirks = append(*quirks, "opt+")
+			}
+		case layers.TCPOptionKindNop:
+			layout = append(layout, "nop")
+		case layers.TCPOptionKindMSS:
+			layout = append(layout, "mss")
+			if len(opt.OptionData) == 2 {
+				mss = int(opt.OptionData[0])<<8 | int(opt.OptionData[1])
+			} else {
+				*quirks = append(*quirks, "bad")
+			}
+		case layers.TCPOptionKindWindowScale:
+			layout = append(layout, "ws")
+			if len(opt.OptionData) == 1 {
+				scale = int(opt.OptionData[0])
+				if scale > 14 {
+					*quirks = append(*quirks, "exws")
+				}
+			} else {
+				*quirks = append(*quirks, "bad")
+			}
+		case layers.TCPOptionKindSACKPermitted:
+			layout = append(layout, "sok")
+		case layers.TCPOptionKindSACK:
+			layout = append(layout, "sack")
+		case layers.TCPOptionKindTimestamps:
+			layout = append(layout, "ts")
+			if len(opt.OptionData) == 8 {
+				if opt.OptionData[0]|opt.OptionData[1]|opt.OptionData[2]|opt.OptionData[3] == 0 {
+					*quirks = append(*quirks, "ts1-")
+				}
+				if !tcp.ACK && opt.OptionData[4]|opt.OptionData[5]|opt.OptionData[6]|opt.OptionData[7] != 0 {
+					*quirks = append(*quirks, "ts2+")
+				}
+			} else {
+				*quirks = append(*quirks, "bad")
+			}
+		default:
+			layout = append(layout, fmt.Sprintf("?%d", opt.OptionType))
+		}
+	}
+	return strings.Join(layout, ","), mss, scale
+}
+
+// guessInitialTTL rounds an observed TTL up to the usual initial values.
+func guessInitialTTL(ttl int) int {
+	for _, initial := range []int{32, 64, 128} {
+		if ttl <= initial {
+			return initial
+		}
+	}
+	return 255
+}
+
+// applyOSFingerprints copies the OS guesses into the passive results.
+func applyOSFingerprints() {
+	for i := range Passive_results {
+		if d, ok := osDetails[Passive_results[i].SrcIP]; ok {
+			setColumns(&Passive_results[i], d.columns())
+		}
+	}
+	for i := range PassiveHost_results {
+		if d, ok := osDetails[PassiveHost_results[i].IP]; ok {
+			setColumns(&PassiveHost_results[i], d.columns())
+		}
+	}
+}
+
+// columns returns the result columns filled from this detail record.
+func (d *osDetail) columns() map[string]string {
+	guess := d.guess
+	if guess != "" && !d.exact {
+		guess += " (fuzzy)"
+	}
+	return map[string]string{
+		"OSGuess":  guess,
+		"Distance": strconv.Itoa(d.distance),
+	}
+}
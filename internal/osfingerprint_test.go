@@ -0,0 +1,149 @@
+package internal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTCPSignature(t *testing.T) {
+	tests := []struct {
+		value string
+		want  tcpSignature
+	}{
+		{"*:64:0:*:mss*44,7:mss,sok,ts,nop,ws:df,id+:0", tcpSignature{
+			version: -1, ittl: 64, mss: -1, wsize: "mss*44", scale: 7,
+			olayout: "mss,sok,ts,nop,ws", quirks: []string{"df", "id+"}, pclass: "0"}},
+		{"4:128-:0:1460:65535,8:mss,nop,ws,nop,nop,sok:id+,df:+", tcpSignature{
+			version: 4, ittl: 128, badTTL: true, mss: 1460, wsize: "65535", scale: 8,
+			olayout: "mss,nop,ws,nop,nop,sok", quirks: []string{"df", "id+"}, pclass: "+"}},
+		{"6:64:0:*:%8192,*:mss::*", tcpSignature{
+			version: 6, ittl: 64, mss: -1, wsize: "%8192", scale: -1, olayout: "mss", pclass: "*"}},
+	}
+	for _, tt := range tests {
+		got, err := parseTCPSignature(tt.value)
+		if err != nil {
+			t.Errorf("parseTCPSignature(%q): %v", tt.value, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseTCPSignature(%q) = %+v, want %+v", tt.value, got, tt.want)
+		}
+	}
+
+	for _, value := range []string{
+		"*:64:0:*:mss*44,7:mss:df",           // too few fields
+		"x:64:0:*:mss*44,7:mss:df:0",         // bad version
+		"*:ttl:0:*:mss*44,7:mss:df:0",        // bad TTL
+		"*:64:0:*:mss*44:mss:df:0",           // no window scale
+		"*:64:0:big:mss*44,7:mss:df:0",       // bad MSS
+		"*:64:0:*:mss*44,7:mss:df:0:0:extra", // too many fields
+	} {
+		if _, err := parseTCPSignature(value); err == nil {
+			t.Errorf("parseTCPSignature(%q): expected an error", value)
+		}
+	}
+}
+
+func TestTCPSignatureWindow(t *testing.T) {
+	tests := []struct {
+		wsize string
+		obs   tcpObservation
+		want  bool
+	}{
+		{"*", tcpObservation{wsize: 1}, true},
+		{"64240", tcpObservation{wsize: 64240}, true},
+		{"64240", tcpObservation{wsize: 65535}, false},
+		{"mss*44", tcpObservation{mss: 1460, wsize: 64240}, true},
+		{"mss*44", tcpObservation{mss: 1400, wsize: 64240}, false},
+		{"mss*44", tcpObservation{wsize: 0}, false},
+		{"mtu*4", tcpObservation{version: 4, mss: 1460, wsize: 6000}, true},
+		{"mtu*4", tcpObservation{version: 6, mss: 1440, wsize: 6000}, true},
+		{"%8192", tcpObservation{wsize: 16384}, true},
+		{"%8192", tcpObservation{wsize: 16000}, false},
+		{"%0", tcpObservation{wsize: 16384}, false},
+	}
+	for _, tt := range tests {
+		sig := tcpSignature{wsize: tt.wsize}
+		if got := sig.windowMatches(&tt.obs); got != tt.want {
+			t.Errorf("window %s with %+v = %v, want %v", tt.wsize, tt.obs, got, tt.want)
+		}
+	}
+}
+
+func TestMatchTCPSignature(t *testing.T) {
+	linux := tcpObservation{version: 4, ttl: 61, mss: 1460, wsize: 64240, scale: 7,
+		olayout: "mss,sok,ts,nop,ws", quirks: []string{"df", "id+"}}
+	windows := tcpObservation{version: 4, ttl: 117, mss: 1460, wsize: 64240, scale: 8,
+		olayout: "mss,nop,ws,nop,nop,sok", quirks: []string{"df", "id+"}}
+	rewritten := linux
+	rewritten.quirks = nil
+	oddWindow := linux
+	oddWindow.wsize, oddWindow.scale = 12345, 3
+	farAway := linux
+	farAway.ttl = 64 - maxTTLDistance - 1
+	payload := linux
+	payload.payload = true
+	unknown := linux
+	unknown.olayout = "mss,eol"
+
+	tests := []struct {
+		name  string
+		obs   tcpObservation
+		label string
+		exact bool
+	}{
+		{"linux", linux, "Linux 4.x-6.x", true},
+		{"windows", windows, "Windows 10 or 11", true},
+		{"quirks rewritten by a middlebox", rewritten, "Linux 4.x-6.x", false},
+		{"generic fallback", oddWindow, "Linux 2.2.x-6.x", true},
+		{"too many hops", farAway, "", false},
+		{"unexpected payload", payload, "", false},
+		{"unknown options", unknown, "", false},
+	}
+	for _, tt := range tests {
+		sig, exact := matchTCPSignature(synSignatures, &tt.obs)
+		label := ""
+		if sig != nil {
+			label = sig.label
+		}
+		if label != tt.label || exact != tt.exact {
+			t.Errorf("%s: matched %q (exact %v), want %q (exact %v)", tt.name, label, exact, tt.label, tt.exact)
+		}
+	}
+}
+
+func TestLoadTCPSignatures(t *testing.T) {
+	data := `
+[tcp:request]
+label = s:unix:Linux:3.11 and newer
+sys   = Linux
+sig   = *:64:0:*:mss*20,10:mss,sok,ts,nop,ws:df,id+:0
+label = g:unix:Linux:generic
+sig   = *:64:0:*:*,*:mss,sok,ts,nop,ws:df,id+:0
+
+[mtu]
+label = Ethernet
+sig   = 1500
+
+[tcp:response]
+label = s:win:Windows:XP
+sig   = *:128:0:*:65535,0:mss,nop,nop,sok:df,id+:0
+`
+	request, response := loadTCPSignatures(data)
+	if len(request) != 2 || len(response) != 1 {
+		t.Fatalf("loaded %d request and %d response signatures, want 2 and 1", len(request), len(response))
+	}
+	if request[0].label != "Linux 3.11 and newer" || request[0].generic {
+		t.Errorf("first signature is %q (generic %v)", request[0].label, request[0].generic)
+	}
+	if request[1].label != "Linux generic" || !request[1].generic {
+		t.Errorf("second signature is %q (generic %v)", request[1].label, request[1].generic)
+	}
+	if response[0].label != "Windows XP" {
+		t.Errorf("response signature is %q", response[0].label)
+	}
+
+	if len(synSignatures) == 0 || len(synAckSignatures) == 0 {
+		t.Error("the embedded p0f database has no signatures")
+	}
+}
@@ -0,0 +1,267 @@
+package internal
+
+import (
+	"bytes"
+	"embed"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Naman1997/discovr/verbose"
+)
+
+// ouiData holds snapshots of the IEEE MA-L (oui.csv), MA-M (mam.csv) and
+// MA-S (oui36.csv) registries. Assignments newer than the snapshots come from
+// the files given to RefreshOUI.
+//
+//go:embed data/oui.csv data/mam.csv data/oui36.csv
+var ouiData embed.FS
+
+var ouiFiles = []string{"data/oui.csv", "data/mam.csv", "data/oui36.csv"}
+
+// LocallyAdministered is the vendor reported for MACs with the U/L bit set,
+// which are randomised by the device or assigned by a hypervisor or cloud.
+const LocallyAdministered = "Locally administered (random)"
+
+// Group addresses are not assigned to a device, so they have no vendor.
+const (
+	Broadcast = "Broadcast"
+	Multicast = "Multicast"
+)
+
+var broadcastMAC = net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+
+var (
+	ouiMu       sync.RWMutex
+	ouiRegistry map[string]string // upper case hex prefix (6, 7 or 9 digits) to organization, nil until loaded
+)
+
+// OUICachePath is where RefreshOUI stores the refreshed registry.
+func OUICachePath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "discovr", "oui.csv"), nil
+}
+
+// loadOUI reads the embedded registries, overlaid with the refreshed one if present.
+func loadOUI() map[string]string {
+	registry := make(map[string]string)
+	for _, name := range ouiFiles {
+		file, err := ouiData.Open(name)
+		if err != nil {
+			panic(err)
+		}
+		entries, err := parseOUI(file)
+		file.Close()
+		if err != nil {
+			panic(fmt.Errorf("%s: %w", name, err))
+		}
+		for prefix, org := range entries {
+			registry[prefix] = org
+		}
+	}
+	if path, err := OUICachePath(); err == nil {
+		if file, err := os.Open(path); err == nil {
+			refreshed, err := parseOUI(file)
+			file.Close()
+			if err != nil {
+				verbose.Printf("Ignoring refreshed OUI registry %s: %v\n", path, err)
+			}
+			for prefix, org := range refreshed {
+				registry[prefix] = org
+			}
+		}
+	}
+	return registry
+}
+
+// lookupOUI returns the organization of a hex prefix, loading the registry
+// on first use.
+func lookupOUI(prefix string) (string, bool) {
+	ouiMu.RLock()
+	registry := ouiRegistry
+	ouiMu.RUnlock()
+	if registry == nil {
+		ouiMu.Lock()
+		if ouiRegistry == nil {
+			ouiRegistry = loadOUI()
+		}
+		registry = ouiRegistry
+		ouiMu.Unlock()
+	}
+	org, ok := registry[prefix]
+	return org, ok
+}
+
+// parseOUI reads an IEEE registry CSV (oui.csv, mam.csv or oui36.csv from
+// standards-oui.ieee.org) into a prefix map. Only the Assignment and
+// Organization Name columns are used.
+func parseOUI(r io.Reader) (map[string]string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+	assignment, organization := -1, -1
+	for i, column := range header {
+		switch strings.TrimSpace(strings.TrimPrefix(column, "\ufeff")) {
+		case "Assignment":
+			assignment = i
+		case "Organization Name":
+			organization = i
+		}
+	}
+	if assignment < 0 || organization < 0 {
+		return nil, errors.New("missing Assignment or Organization Name column")
+	}
+
+	registry := make(map[string]string)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) <= max(assignment, organization) {
+			continue
+		}
+		prefix := strings.ToUpper(strings.TrimSpace(record[assignment]))
+		if n := len(prefix); n != 6 && n != 7 && n != 9 {
+			continue
+		}
+		registry[prefix] = strings.TrimSpace(record[organization])
+	}
+	return registry, nil
+}
+
+// RefreshOUI merges IEEE registry CSV files into the refreshed registry used
+// on top of the embedded snapshot, and returns how many assignments it holds.
+func RefreshOUI(paths []string) (int, error) {
+	registry := make(map[string]string)
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			return 0, err
+		}
+		entries, err := parseOUI(file)
+		file.Close()
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", path, err)
+		}
+		for prefix, org := range entries {
+			registry[prefix] = org
+		}
+		verbose.VerbosePrintf("Read %d assignments from %s\n", len(entries), path)
+	}
+	if len(registry) == 0 {
+		return 0, errors.New("no assignments found")
+	}
+
+	cachePath, err := OUICachePath()
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0755); err != nil {
+		return 0, err
+	}
+	prefixes := make([]string, 0, len(registry))
+	for prefix := range registry {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+
+	tmp, err := os.CreateTemp(filepath.Dir(cachePath), ".oui-*.csv")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+	w := csv.NewWriter(tmp)
+	w.Write([]string{"Registry", "Assignment", "Organization Name"})
+	for _, prefix := range prefixes {
+		w.Write([]string{ouiRegistryName(prefix), prefix, registry[prefix]})
+	}
+	w.Flush()
+	if err := errors.Join(w.Error(), tmp.Close()); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmp.Name(), cachePath); err != nil {
+		return 0, err
+	}
+
+	// Lookups made after the refresh use the new registry
+	reloaded := loadOUI()
+	ouiMu.Lock()
+	ouiRegistry = reloaded
+	ouiMu.Unlock()
+	return len(registry), nil
+}
+
+func ouiRegistryName(prefix string) string {
+	switch len(prefix) {
+	case 7:
+		return "MA-M"
+	case 9:
+		return "MA-S"
+	}
+	return "MA-L"
+}
+
+// MACVendor returns the organization a MAC address is assigned to, trying
+// the MA-S, MA-M and MA-L blocks in turn. Group (broadcast and multicast) and
+// locally administered addresses are reported as such since they are not
+// assigned to a device by the IEEE.
+func MACVendor(mac string) string {
+	hw, err := net.ParseMAC(mac)
+	if err != nil || len(hw) < 6 {
+		return ""
+	}
+	if hw[0]&0x01 != 0 {
+		if bytes.Equal(hw, broadcastMAC) {
+			return Broadcast
+		}
+		return Multicast
+	}
+	if hw[0]&0x02 != 0 {
+		return LocallyAdministered
+	}
+	digits := strings.ToUpper(fmt.Sprintf("%x", []byte(hw)))
+	for _, n := range []int{9, 7, 6} {
+		if org, ok := lookupOUI(digits[:n]); ok {
+			return org
+		}
+	}
+	return ""
+}
+
+// macVendors looks up a list of MACs joined by sep, as in the cloud results.
+func macVendors(macs string, sep string) string {
+	var vendors []string
+	for _, mac := range strings.Split(macs, sep) {
+		if vendor := MACVendor(strings.TrimSpace(mac)); vendor != "" && !contains(vendors, vendor) {
+			vendors = append(vendors, vendor)
+		}
+	}
+	return strings.Join(vendors, sep)
+}
+
+// applyVendors fills in the vendor of every passive result's source MAC.
+func applyVendors() {
+	for i := range Passive_results {
+		Passive_results[i].Vendor = MACVendor(Passive_results[i].SrcMAC)
+	}
+	for i := range PassiveHost_results {
+		PassiveHost_results[i].Vendor = MACVendor(PassiveHost_results[i].MAC)
+	}
+}
@@ -0,0 +1,200 @@
+package internal
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestParseOUI(t *testing.T) {
+	data := "\ufeffRegistry,Assignment,Organization Name,Organization Address\n" +
+		"MA-L,0050C2,IEEE Registration Authority,\"445 Hoes Lane\"\n" +
+		"MA-M,0050C2A,Small Vendor,\"x\"\n" +
+		"MA-S,0050C2A12,Tiny Vendor,\"x\"\n" +
+		"MA-L,00005,Short Assignment,\"x\"\n" +
+		"MA-L,001122\n"
+	registry, err := parseOUI(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"0050C2":    "IEEE Registration Authority",
+		"0050C2A":   "Small Vendor",
+		"0050C2A12": "Tiny Vendor",
+	}
+	if len(registry) != len(want) {
+		t.Errorf("got %d assignments, want %d: %v", len(registry), len(want), registry)
+	}
+	for prefix, org := range want {
+		if registry[prefix] != org {
+			t.Errorf("registry[%s] = %q, want %q", prefix, registry[prefix], org)
+		}
+	}
+
+	if _, err := parseOUI(strings.NewReader("Registry,Prefix\nMA-L,000000\n")); err == nil {
+		t.Error("expected an error for a file without an Assignment column")
+	}
+}
+
+func TestMACVendor(t *testing.T) {
+	ouiMu.Lock()
+	saved := ouiRegistry
+	ouiRegistry = map[string]string{
+		"0050C2":    "IEEE Registration Authority",
+		"0050C2A":   "Small Vendor",
+		"0050C2A12": "Tiny Vendor",
+		"005056":    "VMware, Inc.",
+	}
+	ouiMu.Unlock()
+	t.Cleanup(func() {
+		ouiMu.Lock()
+		ouiRegistry = saved
+		ouiMu.Unlock()
+	})
+
+	tests := []struct {
+		mac  string
+		want string
+	}{
+		{"00:50:56:12:34:56", "VMware, Inc."},
+		{"00-50-56-12-34-56", "VMware, Inc."},
+		{"00:50:c2:a1:23:45", "Tiny Vendor"},
+		{"00:50:c2:a9:99:99", "Small Vendor"},
+		{"00:50:c2:b0:00:01", "IEEE Registration Authority"},
+		{"00:11:22:33:44:55", ""},
+		{"02:42:ac:11:00:02", LocallyAdministered},
+		{"da:a1:19:00:00:01", LocallyAdministered},
+		{"ff:ff:ff:ff:ff:ff", Broadcast},
+		{"01:00:5e:00:00:fb", Multicast},
+		{"33:33:00:00:00:01", Multicast},
+		{"03:00:00:00:00:01", Multicast},
+		{"not a mac", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := MACVendor(tt.mac); got != tt.want {
+			t.Errorf("MACVendor(%q) = %q, want %q", tt.mac, got, tt.want)
+		}
+	}
+
+	if got := macVendors("00:50:56:00:00:01, 00:50:56:00:00:02, 02:00:00:00:00:01", ", "); got != "VMware, Inc., "+LocallyAdministered {
+		t.Errorf("macVendors = %q", got)
+	}
+}
+
+func TestEmbeddedOUI(t *testing.T) {
+	// Keep a refreshed registry of the user out of the test
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("AppData", t.TempDir())
+
+	registry := loadOUI()
+	if got := registry["005056"]; !strings.HasPrefix(got, "VMware") {
+		t.Errorf("embedded registry has %q for 005056, want VMware", got)
+	}
+	for prefix := range registry {
+		if n := len(prefix); n != 6 && n != 7 && n != 9 {
+			t.Fatalf("embedded registry has a %d digit prefix %q", n, prefix)
+		}
+	}
+}
+
+func TestEmbeddedMAMAndMAS(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("AppData", t.TempDir())
+	t.Cleanup(func() {
+		ouiMu.Lock()
+		ouiRegistry = nil
+		ouiMu.Unlock()
+	})
+	ouiMu.Lock()
+	ouiRegistry = nil
+	ouiMu.Unlock()
+
+	tests := []struct {
+		file   string
+		digits int
+	}{
+		{"data/mam.csv", 7},
+		{"data/oui36.csv", 9},
+	}
+	for _, tt := range tests {
+		file, err := ouiData.Open(tt.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries, err := parseOUI(file)
+		file.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.file, err)
+		}
+		if len(entries) == 0 {
+			t.Skipf("%s is an empty snapshot, run make get_oui to fetch the registry", tt.file)
+		}
+		var prefixes []string
+		for prefix := range entries {
+			prefixes = append(prefixes, prefix)
+		}
+		sort.Strings(prefixes)
+		// The first, last and a middle assignment resolve to their own
+		// organization, not to the MA-L block of the registration authority
+		for _, prefix := range []string{prefixes[0], prefixes[len(prefixes)/2], prefixes[len(prefixes)-1]} {
+			if len(prefix) != tt.digits {
+				t.Errorf("%s has a %d digit prefix %q", tt.file, len(prefix), prefix)
+				continue
+			}
+			hex := (prefix + "000000000000")[:12]
+			mac := strings.ToLower(hex[0:2] + ":" + hex[2:4] + ":" + hex[4:6] + ":" + hex[6:8] + ":" + hex[8:10] + ":" + hex[10:12])
+			if hw, _ := net.ParseMAC(mac); hw[0]&0x03 != 0 {
+				continue
+			}
+			if got := MACVendor(mac); got != entries[prefix] {
+				t.Errorf("MACVendor(%s) = %q, want %q from %s", mac, got, entries[prefix], tt.file)
+			}
+		}
+	}
+}
+
+func TestRefreshOUIDuringLookups(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("AppData", t.TempDir())
+	t.Cleanup(func() {
+		ouiMu.Lock()
+		ouiRegistry = nil
+		ouiMu.Unlock()
+	})
+
+	path := filepath.Join(t.TempDir(), "mam.csv")
+	if err := os.WriteFile(path, []byte("Registry,Assignment,Organization Name\nMA-M,0050561,Refreshed Vendor\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Scans look vendors up from many goroutines while a refresh swaps the registry
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 1000 {
+				MACVendor("00:50:56:12:34:56")
+			}
+		}()
+	}
+	if _, err := RefreshOUI([]string{path}); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+
+	if got := MACVendor("00:50:56:12:34:56"); got != "Refreshed Vendor" {
+		t.Errorf("after refresh MACVendor = %q, want the MA-M assignment", got)
+	}
+	if got := MACVendor("00:50:56:22:34:56"); !strings.HasPrefix(got, "VMware") {
+		t.Errorf("after refresh MACVendor = %q, want the MA-L assignment", got)
+	}
+}
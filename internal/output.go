@@ -41,8 +41,47 @@ func ExportCSV[T any](filePath string, data []T) error {
 	}
 	defer file.Close()
 
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
+	if err := writeCSV(file, data); err != nil {
+		return err
+	}
+
+	fmt.Printf("Saved to: %v\n", filePath)
+	return nil
+}
+
+// WriteCSV replaces the contents of filePath with data. The file is written
+// under a temporary name and renamed into place, so a crash never leaves a
+// half-written export behind. Used for periodic checkpoints of long scans.
+func WriteCSV[T any](filePath string, data []T) error {
+	if filePath == "" {
+		return nil
+	}
+	if filepath.Ext(filePath) != ".csv" {
+		filePath = filePath + ".csv"
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(filePath), filepath.Base(filePath)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("error creating file: %v", err)
+	}
+	defer os.Remove(file.Name())
+	if err := file.Chmod(0644); err != nil {
+		file.Close()
+		return err
+	}
+	if err := writeCSV(file, data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), filePath)
+}
+
+// writeCSV writes a header of field names followed by one record per element.
+func writeCSV[T any](w io.Writer, data []T) error {
+	writer := csv.NewWriter(w)
 
 	v := reflect.ValueOf(data)
 	elemType := reflect.TypeOf(data).Elem()
@@ -66,9 +105,21 @@ func ExportCSV[T any](filePath string, data []T) error {
 			return fmt.Errorf("failed to write record: %w", err)
 		}
 	}
+	writer.Flush()
+	return writer.Error()
+}
 
-	fmt.Printf("Saved to: %v\n", filePath)
-	return nil
+// ExportPathWithSuffix inserts suffix before the .csv extension of filePath so
+// one scan can export several related tables side by side.
+func ExportPathWithSuffix(filePath string, suffix string) string {
+	if filePath == "" {
+		return ""
+	}
+	ext := filepath.Ext(filePath)
+	if ext != ".csv" {
+		return filePath + suffix + ".csv"
+	}
+	return filePath[:len(filePath)-len(ext)] + suffix + ext
 }
 
 func UploadResults[T any](url string, filePath string, data []T, filePrefix string) {
@@ -1,15 +1,21 @@
 package internal
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"fmt"
 	"net"
+	"os"
 	"slices"
+	"sync"
 	"time"
 
 	"github.com/Naman1997/discovr/verbose"
 	"github.com/google/gopacket"
 	"github.com/google/gopacket/layers"
 	"github.com/google/gopacket/pcap"
+	"github.com/google/gopacket/pcapgo"
 	"golang.org/x/sync/semaphore"
 )
 
@@ -21,32 +27,164 @@ var (
 
 // export vars
 type ScanResultPassive struct {
-	SrcIP        string
-	Protocol     string
-	SrcMAC       string
-	DstMAC       string
-	EthernetType string
+	SrcIP         string
+	Protocol      string
+	SrcMAC        string
+	DstMAC        string
+	EthernetType  string
+	FirstSeen     string
+	Hostname      string
+	VendorClass   string
+	DeviceGuess   string
+	Services      string
+	UPnP          string
+	NetBIOSName   string
+	Workgroup     string
+	SNI           string
+	JA3           string
+	JA4           string
+	CertSubject   string
+	CertIssuer    string
+	CertSANs      string
+	CertExpiry    string
+	OSGuess       string
+	Distance      string
+	IPv6LinkLocal string
+	IPv6Global    string
+	IPv6Privacy   string
+	Interface     string
+	VLAN          string
+	Vendor        string
 }
 
-func PassiveScan(device string, scanSeconds int) {
+// PassiveOptions holds the optional settings of a passive scan.
+type PassiveOptions struct {
+	Capture      CaptureOptions
+	Filter       string // BPF expression applied to the capture handle
+	Promisc      bool
+	SnapLen      int  // bytes captured per packet, 0 uses defaultSnapLen
+	BufferSizeMB int  // kernel capture buffer size, 0 keeps the libpcap default
+	TrackAll     bool // track every endpoint and conversation, not just traffic to this host
+}
+
+const (
+	defaultSnapLen = 1024
+	// tlsSnapLen is the snap length that keeps whole TCP segments, which TLS
+	// decoding needs since streams with a truncated packet are dropped
+	tlsSnapLen = 65535
+)
+
+// WarnSnapLen warns before a live capture when the snap length truncates the
+// packets carrying TLS handshakes, leaving SNI, JA3/JA4 and certificates empty.
+func WarnSnapLen(snapLen int) {
+	if snapLen = snapLenOrDefault(snapLen); snapLen < tlsSnapLen {
+		verbose.Printf("Capturing %d bytes per packet truncates TLS handshakes, so SNI, JA3/JA4 and certificates are not decoded; use --snaplen %d to see them\n", snapLen, tlsSnapLen)
+	}
+}
+
+// ValidateBPFFilter compiles a BPF expression without opening a device so an
+// invalid --filter is reported before the capture starts.
+func ValidateBPFFilter(expr string, snapLen int) error {
+	if expr == "" {
+		return nil
+	}
+	if snapLen <= 0 {
+		snapLen = defaultSnapLen
+	}
+	if _, err := pcap.CompileBPFFilter(layers.LinkTypeEthernet, snapLen, expr); err != nil {
+		return fmt.Errorf("invalid BPF filter %q: %v", expr, err)
+	}
+	return nil
+}
+
+// pcapngMagic is the block type of the section header that starts every pcapng file
+var pcapngMagic = []byte{0x0a, 0x0d, 0x0d, 0x0a}
+
+// PassiveScan captures on every device at once and merges what each of them
+// sees into one set of results.
+func PassiveScan(devices []string, scanSeconds int, opts PassiveOptions) {
 
 	// Initialize context and define scanDuration
 	var scanDuration time.Duration = time.Duration(scanSeconds) * time.Second
 	ctx, cancel := context.WithTimeout(context.Background(), scanDuration)
-	go capturePackets(ctx, sem, device, scanDuration)
+	go capturePackets(ctx, sem, devices, scanDuration, opts)
 
 	// Wait for the scanDuration and wg to finish
 	time.Sleep(scanDuration)
 	defer cancel()
 
-	err := sem.Acquire(ctx, 2)
+	// Wait for capturePackets to release its slot so the capture file is flushed
+	err := sem.Acquire(context.Background(), 2)
 	if err != nil {
 		verbose.Printf("")
 		return
 	}
+	sem.Release(2)
+
+	finishPassive(opts)
 }
 
-func capturePackets(ctx context.Context, sem *semaphore.Weighted, networkInterface string, scanDuration time.Duration) {
+// PassiveScanFile runs the same asset extraction as PassiveScan over a pcap or
+// pcapng file. Packets are processed as fast as they can be read and results
+// are stamped with the capture time of the packet instead of the wall clock.
+func PassiveScanFile(path string, opts PassiveOptions) {
+	file, err := os.Open(path)
+	if err != nil {
+		verbose.VerboseFatalfMsg("Unable to open capture file: %v", err)
+	}
+	defer file.Close()
+
+	source, linkType, err := captureFileSource(bufio.NewReader(file))
+	if err != nil {
+		verbose.VerboseFatalfMsg("Unable to read capture file %s: %v", path, err)
+	}
+	source.DecodeOptions = gopacket.DecodeOptions{Lazy: true, NoCopy: true}
+
+	var filter *pcap.BPF
+	if opts.Filter != "" {
+		filter, err = pcap.NewBPF(linkType, snapLenOrDefault(opts.SnapLen), opts.Filter)
+		if err != nil {
+			verbose.VerboseFatalfMsg("invalid BPF filter %q: %v", opts.Filter, err)
+		}
+	}
+
+	recorder := newCaptureRecorder(opts.Capture, linkType)
+	defer recorder.Close()
+
+	// The capturing host is unknown, so every destination counts as local
+	processed := 0
+	for packet := range source.Packets() {
+		if filter != nil && !filter.Matches(packet.Metadata().CaptureInfo, packet.Data()) {
+			continue
+		}
+		processPacket(packet, "", nil, opts, recorder)
+		processed++
+	}
+	finishPassive(opts)
+	verbose.VerbosePrintf("Processed %d packets from %s\n", processed, path)
+}
+
+// captureFileSource picks the pcapng or classic pcap reader based on the file magic
+func captureFileSource(r *bufio.Reader) (*gopacket.PacketSource, layers.LinkType, error) {
+	magic, err := r.Peek(len(pcapngMagic))
+	if err != nil {
+		return nil, 0, fmt.Errorf("file too short: %w", err)
+	}
+	if bytes.Equal(magic, pcapngMagic) {
+		ng, err := pcapgo.NewNgReader(r, pcapgo.DefaultNgReaderOptions)
+		if err != nil {
+			return nil, 0, err
+		}
+		return gopacket.NewPacketSource(ng, ng.LinkType()), ng.LinkType(), nil
+	}
+	reader, err := pcapgo.NewReader(r)
+	if err != nil {
+		return nil, 0, err
+	}
+	return gopacket.NewPacketSource(reader, reader.LinkType()), reader.LinkType(), nil
+}
+
+func capturePackets(ctx context.Context, sem *semaphore.Weighted, devices []string, scanDuration time.Duration, opts PassiveOptions) {
 	err := sem.Acquire(context.Background(), 1)
 	if err != nil {
 		panic(err)
@@ -64,33 +202,149 @@ func capturePackets(ctx context.Context, sem *semaphore.Weighted, networkInterfa
 	defer ticker.Stop()
 	timeout := time.After(scanDuration)
 
-	packets := packets(ctx, sem, networkInterface)
+	packets, linkType := packets(ctx, sem, devices, opts)
+	recorder := newCaptureRecorder(opts.Capture, linkType)
+	defer recorder.Close()
 	for {
 		select {
-		case packet := <-packets:
-			printPacketInfo(packet, localIPs)
+		case p, ok := <-packets:
+			if !ok {
+				return
+			}
+			processPacket(p.packet, p.iface, localIPs, opts, recorder)
 		case <-timeout:
 			return
 		}
 	}
 }
 
-func packets(ctx context.Context, sem *semaphore.Weighted, networkInterface string) chan gopacket.Packet {
-	if handle, err := pcap.OpenLive(networkInterface, 1024, false, pcap.BlockForever); err != nil {
-		panic(err)
-	} else {
-		ps := gopacket.NewPacketSource(handle, handle.LinkType())
-		err := sem.Acquire(context.Background(), 1)
+// packets opens a capture handle on every device and merges their packets,
+// tagged with the device they came from, into one channel. The channel is
+// closed once ctx is done and every handle has been drained.
+func packets(ctx context.Context, sem *semaphore.Weighted, devices []string, opts PassiveOptions) (chan capturedPacket, layers.LinkType) {
+	var handles []*pcap.Handle
+	for _, device := range devices {
+		handle, err := openLiveHandle(device, opts)
 		if err != nil {
-			panic(err)
+			verbose.VerboseFatalfMsg("Unable to capture on %s: %v", device, err)
+		}
+		if len(handles) > 0 && handle.LinkType() != handles[0].LinkType() {
+			verbose.Printf("Warning: %s uses link type %s, recorded packets will be labelled %s\n",
+				device, handle.LinkType(), handles[0].LinkType())
+		}
+		handles = append(handles, handle)
+		verbose.VerbosePrintf("Capturing on %s\n", device)
+	}
+
+	err := sem.Acquire(context.Background(), 1)
+	if err != nil {
+		panic(err)
+	}
+	defer sem.Release(1)
+
+	merged := make(chan capturedPacket, 1000)
+	var wg sync.WaitGroup
+	for i, handle := range handles {
+		wg.Add(1)
+		go func(device string, handle *pcap.Handle) {
+			defer wg.Done()
+			for packet := range gopacket.NewPacketSource(handle, handle.LinkType()).Packets() {
+				select {
+				case merged <- capturedPacket{packet: packet, iface: device}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(devices[i], handle)
+	}
+	go func() {
+		<-ctx.Done()
+		for _, handle := range handles {
+			handle.Close()
 		}
-		defer sem.Release(1)
-		go func() {
-			<-ctx.Done()
+	}()
+	go func() {
+		wg.Wait()
+		close(merged)
+	}()
+	return merged, handles[0].LinkType()
+}
+
+// openLiveHandle activates a capture handle with the snap length, promiscuous
+// mode and buffer size from opts, then installs the BPF filter in the kernel
+// so unwanted traffic never reaches Go-level parsing.
+func openLiveHandle(networkInterface string, opts PassiveOptions) (*pcap.Handle, error) {
+	inactive, err := pcap.NewInactiveHandle(networkInterface)
+	if err != nil {
+		return nil, err
+	}
+	defer inactive.CleanUp()
+
+	if err := inactive.SetSnapLen(snapLenOrDefault(opts.SnapLen)); err != nil {
+		return nil, fmt.Errorf("setting snap length: %v", err)
+	}
+	if err := inactive.SetPromisc(opts.Promisc); err != nil {
+		return nil, fmt.Errorf("setting promiscuous mode: %v", err)
+	}
+	if err := inactive.SetTimeout(pcap.BlockForever); err != nil {
+		return nil, fmt.Errorf("setting timeout: %v", err)
+	}
+	if opts.BufferSizeMB > 0 {
+		if err := inactive.SetBufferSize(opts.BufferSizeMB * 1024 * 1024); err != nil {
+			return nil, fmt.Errorf("setting buffer size: %v", err)
+		}
+	}
+
+	handle, err := inactive.Activate()
+	if err != nil {
+		return nil, err
+	}
+	if opts.Filter != "" {
+		if err := handle.SetBPFFilter(opts.Filter); err != nil {
 			handle.Close()
-		}()
-		return ps.Packets()
+			return nil, fmt.Errorf("invalid BPF filter %q: %v", opts.Filter, err)
+		}
+		verbose.VerbosePrintf("Applied BPF filter: %s\n", opts.Filter)
+	}
+	return handle, nil
+}
+
+func snapLenOrDefault(snapLen int) int {
+	if snapLen <= 0 {
+		return defaultSnapLen
+	}
+	return snapLen
+}
+
+// processPacket runs every enabled analysis over a captured packet.
+func processPacket(packet gopacket.Packet, iface string, localIPs []string, opts PassiveOptions, recorder *captureRecorder) {
+	printPacketInfo(packet, localIPs)
+	observeInterface(packet, iface)
+	observeVLAN(packet)
+	decodeDHCP(packet)
+	decodeAnnouncements(packet)
+	decodeNDP(packet)
+	decodeNeighbours(packet)
+	decodeTLS(packet)
+	decodePassiveDNS(packet)
+	decodeTCPFingerprint(packet)
+	if opts.TrackAll {
+		tracker.observe(packet)
+	}
+	recorder.Write(packet)
+}
+
+// finishPassive builds the final result tables once the capture has ended.
+func finishPassive(opts PassiveOptions) {
+	if opts.TrackAll {
+		finishTracking()
 	}
+	applyHostDetails()
+	applyTLSDetails()
+	applyOSFingerprints()
+	applyPassiveDNSHostnames()
+	applyInterfaces()
+	applyVendors()
 }
 
 // TODO: Wait for SRUM-8 and implement the method to export this information to a csv file
@@ -99,36 +353,48 @@ func printPacketInfo(packet gopacket.Packet, localIPs []string) {
 		return
 	}
 	ethernetLayer := packet.Layer(layers.LayerTypeEthernet)
-	ipLayer := packet.Layer(layers.LayerTypeIPv4)
-	if ipLayer != nil {
+	var srcIP, dstIP net.IP
+	var protocol string
+	if ipLayer := packet.Layer(layers.LayerTypeIPv4); ipLayer != nil {
 		ip, _ := ipLayer.(*layers.IPv4)
-		if slices.Contains(localIPs, ip.DstIP.String()) && !slices.Contains(discoveredAssets, ip.SrcIP.String()) {
-			discoveredAssets = append(discoveredAssets, ip.SrcIP.String())
-			verbose.VerbosePrintf("Discovered new asset: %s\n", ip.SrcIP)
-			verbose.VerbosePrintln("Protocol: ", ip.Protocol)
-			verbose.VerbosePrintln()
+		srcIP, dstIP, protocol = ip.SrcIP, ip.DstIP, ip.Protocol.String()
+	} else if ipLayer := packet.Layer(layers.LayerTypeIPv6); ipLayer != nil {
+		ip, _ := ipLayer.(*layers.IPv6)
+		srcIP, dstIP, protocol = ip.SrcIP, ip.DstIP, ip.NextHeader.String()
+	} else {
+		return
+	}
 
-			if ethernetLayer != nil {
-				verbose.VerbosePrintln("Ethernet layer detected.\n")
-				ethernetPacket, _ := ethernetLayer.(*layers.Ethernet)
-				verbose.VerbosePrintln("Source MAC: ", ethernetPacket.SrcMAC)
-				verbose.VerbosePrintln("Destination MAC: ", ethernetPacket.DstMAC)
-				verbose.VerbosePrintln("Ethernet type: ", ethernetPacket.EthernetType)
-				verbose.VerbosePrintln()
-
-				//export SCRUM-94
-				result := ScanResultPassive{
-					SrcIP:        ip.SrcIP.String(),
-					Protocol:     ip.Protocol.String(),
-					SrcMAC:       ethernetPacket.SrcMAC.String(),
-					DstMAC:       ethernetPacket.DstMAC.String(),
-					EthernetType: ethernetPacket.EthernetType.String(),
-				}
-				Passive_results = append(Passive_results, result)
+	// DHCP clients without a lease send from 0.0.0.0 and IPv6 duplicate address
+	// detection from ::, decodeDHCP and decodeNDP report those
+	isLocal := localIPs == nil || slices.Contains(localIPs, dstIP.String())
+	if isLocal && !srcIP.IsUnspecified() && !slices.Contains(discoveredAssets, srcIP.String()) {
+		discoveredAssets = append(discoveredAssets, srcIP.String())
+		verbose.VerbosePrintf("Discovered new asset: %s\n", srcIP)
+		verbose.VerbosePrintln("Protocol: ", protocol)
+		verbose.VerbosePrintln()
 
+		if ethernetLayer != nil {
+			verbose.VerbosePrintln("Ethernet layer detected.")
+			ethernetPacket, _ := ethernetLayer.(*layers.Ethernet)
+			verbose.VerbosePrintln("Source MAC: ", ethernetPacket.SrcMAC)
+			verbose.VerbosePrintln("Destination MAC: ", ethernetPacket.DstMAC)
+			verbose.VerbosePrintln("Ethernet type: ", ethernetPacket.EthernetType)
+			verbose.VerbosePrintln()
+
+			//export SCRUM-94
+			result := ScanResultPassive{
+				SrcIP:        srcIP.String(),
+				Protocol:     protocol,
+				SrcMAC:       ethernetPacket.SrcMAC.String(),
+				DstMAC:       ethernetPacket.DstMAC.String(),
+				EthernetType: ethernetPacket.EthernetType.String(),
+				FirstSeen:    packetTime(packet).Format(time.RFC3339),
 			}
-			verbose.VerbosePrintln("==========================================================================================")
+			Passive_results = append(Passive_results, result)
+
 		}
+		verbose.VerbosePrintln("==========================================================================================")
 	}
 }
 
@@ -156,3 +422,12 @@ func getLocalIPs() ([]string, error) {
 	}
 	return localIPs, nil
 }
+
+// packetTime returns the capture timestamp of a packet, falling back to the
+// current time for sources that do not record one.
+func packetTime(packet gopacket.Packet) time.Time {
+	if md := packet.Metadata(); md != nil && !md.Timestamp.IsZero() {
+		return md.Timestamp
+	}
+	return time.Now()
+}
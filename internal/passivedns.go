@@ -0,0 +1,204 @@
+package internal
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Naman1997/discovr/verbose"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+const dnsPort = 53
+
+var (
+	PassiveDNS_results []ScanResultPassiveDNS
+	seenDNSAnswers     = make(map[string]int)
+)
+
+// ScanResultPassiveDNS is one answer observed in a DNS response on the wire
+type ScanResultPassiveDNS struct {
+	Query     string
+	Type      string
+	Answer    string
+	TTL       int
+	Server    string
+	FirstSeen string
+	LastSeen  string
+}
+
+// decodePassiveDNS records the answers of DNS responses sent by resolvers.
+// The same answer seen again only refreshes its TTL and last seen time.
+func decodePassiveDNS(packet gopacket.Packet) {
+	dnsLayer := packet.Layer(layers.LayerTypeDNS)
+	if dnsLayer == nil {
+		return
+	}
+	var srcPort layers.UDPPort
+	if udpLayer := packet.Layer(layers.LayerTypeUDP); udpLayer != nil {
+		srcPort = udpLayer.(*layers.UDP).SrcPort
+	}
+	dns := dnsLayer.(*layers.DNS)
+	if srcPort != dnsPort || !dns.QR || dns.ResponseCode != layers.DNSResponseCodeNoErr || len(dns.Questions) == 0 {
+		return
+	}
+
+	var server string
+	if ipLayer := packet.Layer(layers.LayerTypeIPv4); ipLayer != nil {
+		server = ipLayer.(*layers.IPv4).SrcIP.String()
+	} else if ipLayer := packet.Layer(layers.LayerTypeIPv6); ipLayer != nil {
+		server = ipLayer.(*layers.IPv6).SrcIP.String()
+	}
+	seen := packetTime(packet).Format(time.RFC3339)
+
+	// Answers to a CNAME chain are filed under the name that was asked for
+	query := strings.TrimSuffix(string(dns.Questions[0].Name), ".")
+	for _, rr := range dns.Answers {
+		answer := dnsAnswer(rr)
+		if answer == "" {
+			continue
+		}
+		rrType := rr.Type.String()
+		key := query + "|" + rrType + "|" + answer
+		if i, ok := seenDNSAnswers[key]; ok {
+			PassiveDNS_results[i].TTL = int(rr.TTL)
+			PassiveDNS_results[i].LastSeen = seen
+			continue
+		}
+		seenDNSAnswers[key] = len(PassiveDNS_results)
+		PassiveDNS_results = append(PassiveDNS_results, ScanResultPassiveDNS{
+			Query:     query,
+			Type:      rrType,
+			Answer:    answer,
+			TTL:       int(rr.TTL),
+			Server:    server,
+			FirstSeen: seen,
+			LastSeen:  seen,
+		})
+		verbose.VerbosePrintf("DNS %s %s -> %s (ttl %d) from %s\n", rrType, query, answer, rr.TTL, server)
+	}
+}
+
+// dnsAnswer renders the data of the record types useful for naming hosts.
+func dnsAnswer(rr layers.DNSResourceRecord) string {
+	switch rr.Type {
+	case layers.DNSTypeA, layers.DNSTypeAAAA:
+		return rr.IP.String()
+	case layers.DNSTypeCNAME:
+		return string(rr.CNAME)
+	case layers.DNSTypePTR:
+		return string(rr.PTR)
+	case layers.DNSTypeNS:
+		return string(rr.NS)
+	case layers.DNSTypeMX:
+		return string(rr.MX.Name)
+	case layers.DNSTypeSRV:
+		return fmt.Sprintf("%s:%d", rr.SRV.Name, rr.SRV.Port)
+	}
+	return ""
+}
+
+// passiveDNSHostnames maps addresses to names using the A, AAAA and PTR
+// answers of the passive DNS table. The first name seen for an address wins.
+func passiveDNSHostnames() map[string]string {
+	names := make(map[string]string)
+	for _, r := range PassiveDNS_results {
+		var ip, name string
+		switch r.Type {
+		case layers.DNSTypeA.String(), layers.DNSTypeAAAA.String():
+			ip, name = r.Answer, r.Query
+		case layers.DNSTypePTR.String():
+			if addr := reverseDNSAddress(r.Query); addr != nil {
+				ip, name = addr.String(), strings.TrimSuffix(r.Answer, ".")
+			}
+		}
+		if _, ok := names[ip]; ip != "" && !ok {
+			names[ip] = name
+		}
+	}
+	return names
+}
+
+// reverseDNSAddress parses 4.3.2.1.in-addr.arpa and nibble-format ip6.arpa names.
+func reverseDNSAddress(name string) net.IP {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	if v4, ok := strings.CutSuffix(name, ".in-addr.arpa"); ok {
+		labels := strings.Split(v4, ".")
+		if len(labels) != 4 {
+			return nil
+		}
+		for i, j := 0, len(labels)-1; i < j; i, j = i+1, j-1 {
+			labels[i], labels[j] = labels[j], labels[i]
+		}
+		return net.ParseIP(strings.Join(labels, "."))
+	}
+	if v6, ok := strings.CutSuffix(name, ".ip6.arpa"); ok {
+		nibbles := strings.Split(v6, ".")
+		if len(nibbles) != 32 {
+			return nil
+		}
+		ip := make(net.IP, net.IPv6len)
+		for i, nibble := range nibbles {
+			v, err := strconv.ParseUint(nibble, 16, 4)
+			if err != nil {
+				return nil
+			}
+			pos := 31 - i
+			ip[pos/2] |= byte(v) << (4 * (1 - pos%2))
+		}
+		return ip
+	}
+	return nil
+}
+
+// applyPassiveDNSHostnames fills the empty hostnames of passive results from
+// the passive DNS table. Names devices announced for themselves are kept.
+func applyPassiveDNSHostnames() {
+	names := passiveDNSHostnames()
+	for i := range Passive_results {
+		if name, ok := names[Passive_results[i].SrcIP]; ok && Passive_results[i].Hostname == "" {
+			Passive_results[i].Hostname = name
+		}
+	}
+	for i := range PassiveHost_results {
+		if name, ok := names[PassiveHost_results[i].IP]; ok && PassiveHost_results[i].Hostname == "" {
+			PassiveHost_results[i].Hostname = name
+		}
+	}
+}
+
+// applyPassiveDNSToActive fills the hostnames that reverse DNS could not
+// resolve in ARP and ICMP scan results from a loaded passive DNS table.
+func applyPassiveDNSToActive() {
+	if len(PassiveDNS_results) == 0 {
+		return
+	}
+	names := passiveDNSHostnames()
+	for i := range Defaultscan_results {
+		if name, ok := names[Defaultscan_results[i].Dest_IP]; ok && Defaultscan_results[i].Hostname == "" {
+			Defaultscan_results[i].Hostname = name
+		}
+	}
+	for i := range Icmpscan_results {
+		if name, ok := names[Icmpscan_results[i].IP]; ok && Icmpscan_results[i].Hostname == "" {
+			Icmpscan_results[i].Hostname = name
+		}
+	}
+}
+
+// LoadPassiveDNS reads a passive DNS table exported by an earlier passive
+// scan so active scans can name hosts without PTR records.
+func LoadPassiveDNS(path string) error {
+	kind, err := ImportFile(path)
+	if err != nil {
+		return err
+	}
+	if kind != ImportPassiveDNS {
+		return fmt.Errorf("%s is a %s export, not a passive DNS table", path, kind)
+	}
+	verbose.VerbosePrintf("Loaded %d passive DNS answers from %s\n", len(PassiveDNS_results), path)
+	return nil
+}
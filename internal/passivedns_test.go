@@ -0,0 +1,39 @@
+package internal
+
+import (
+	"net"
+	"testing"
+)
+
+func TestReverseDNSAddress(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"10.2.0.192.in-addr.arpa", "192.0.2.10"},
+		{"10.2.0.192.in-addr.arpa.", "192.0.2.10"},
+		{"10.2.0.192.IN-ADDR.ARPA", "192.0.2.10"},
+		{"b.a.9.8.7.6.5.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa", "2001:db8::567:89ab"},
+		{"B.A.9.8.7.6.5.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.B.D.0.1.0.0.2.ip6.arpa.", "2001:db8::567:89ab"},
+		{"2.0.192.in-addr.arpa", ""},
+		{"1.10.2.0.192.in-addr.arpa", ""},
+		{"300.2.0.192.in-addr.arpa", ""},
+		{"b.a.9.8.7.6.5.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.ip6.arpa", ""},
+		{"g.a.9.8.7.6.5.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa", ""},
+		{"ba.9.8.7.6.5.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa", ""},
+		{"printer.example.com", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		got := reverseDNSAddress(tt.name)
+		if tt.want == "" {
+			if got != nil {
+				t.Errorf("reverseDNSAddress(%q) = %v, want nil", tt.name, got)
+			}
+			continue
+		}
+		if !got.Equal(net.ParseIP(tt.want)) {
+			t.Errorf("reverseDNSAddress(%q) = %v, want %s", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/Naman1997/discovr/verbose"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+)
+
+// CaptureOptions configures recording of passively captured packets to pcapng.
+type CaptureOptions struct {
+	Path           string        // output file, empty disables recording
+	MaxSizeMB      int           // start a new file once the current one exceeds this size, 0 disables
+	RotateEvery    time.Duration // start a new file after this much capture time, 0 disables
+	SamplePerAsset int           // only keep the first N packets sent by each discovered asset, 0 keeps everything
+}
+
+// captureRecorder writes packets to a rotating set of pcapng files.
+type captureRecorder struct {
+	opts     CaptureOptions
+	linkType layers.LinkType
+	file     *os.File
+	counter  *countingWriter
+	writer   *pcapgo.NgWriter
+	opened   time.Time
+	samples  map[string]int
+	assets   map[string]bool // MACs seen sending from a discovered address
+	failed   bool
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func newCaptureRecorder(opts CaptureOptions, linkType layers.LinkType) *captureRecorder {
+	if opts.Path == "" {
+		return nil
+	}
+	if filepath.Ext(opts.Path) != ".pcapng" {
+		opts.Path = opts.Path + ".pcapng"
+		verbose.Printf("Capture path did not have .pcapng extension, saving as: %s\n", opts.Path)
+	}
+	return &captureRecorder{
+		opts:     opts,
+		linkType: linkType,
+		samples:  make(map[string]int),
+		assets:   make(map[string]bool),
+	}
+}
+
+// Write records a packet, rotating the output file when a size or time limit
+// is reached. Errors are logged once and stop further recording.
+func (r *captureRecorder) Write(packet gopacket.Packet) {
+	if r == nil || r.failed || packet == nil {
+		return
+	}
+
+	if r.opts.SamplePerAsset > 0 {
+		src, mac := packetSourceIP(packet), packetSourceMAC(packet)
+		if src != "" {
+			// Devices found by the decoders, such as IPv6 hosts seen through
+			// NDP, count as discovered before the results are built
+			if !slices.Contains(discoveredAssets, src) && hostDetails[mac] == nil {
+				return
+			}
+			if mac != "" {
+				r.assets[mac] = true
+			}
+		} else if src = mac; src == "" || (!r.assets[mac] && hostDetails[mac] == nil) {
+			// ARP, LLDP, CDP and addressless DHCP or NDP frames are sampled
+			// per MAC, once the MAC belongs to a discovered asset
+			return
+		}
+		if r.samples[src] >= r.opts.SamplePerAsset {
+			return
+		}
+		r.samples[src]++
+	}
+
+	ts := packetTime(packet)
+	if err := r.rotate(ts); err != nil {
+		r.fail(err)
+		return
+	}
+
+	ci := packet.Metadata().CaptureInfo
+	ci.InterfaceIndex = 0
+	if ci.Timestamp.IsZero() {
+		ci.Timestamp = ts
+	}
+	if err := r.writer.WritePacket(ci, packet.Data()); err != nil {
+		r.fail(err)
+	}
+}
+
+// rotate opens the first file, or closes the current one and opens the next
+// if it grew past MaxSizeMB or has been open longer than RotateEvery.
+func (r *captureRecorder) rotate(ts time.Time) error {
+	if r.writer != nil {
+		tooBig := r.opts.MaxSizeMB > 0 && r.counter.n >= int64(r.opts.MaxSizeMB)*1024*1024
+		tooOld := r.opts.RotateEvery > 0 && ts.Sub(r.opened) >= r.opts.RotateEvery
+		if !tooBig && !tooOld {
+			return nil
+		}
+		if err := r.closeFile(); err != nil {
+			return err
+		}
+	}
+
+	path := nextCapturePath(r.opts.Path)
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0644)
+	if err != nil {
+		return fmt.Errorf("error creating capture file: %v", err)
+	}
+	counter := &countingWriter{w: file}
+	writer, err := pcapgo.NewNgWriter(counter, r.linkType)
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("error writing pcapng header: %v", err)
+	}
+
+	r.file, r.counter, r.writer, r.opened = file, counter, writer, ts
+	verbose.VerbosePrintf("Recording packets to %s\n", path)
+	return nil
+}
+
+func (r *captureRecorder) closeFile() error {
+	if r.writer == nil {
+		return nil
+	}
+	flushErr := r.writer.Flush()
+	closeErr := r.file.Close()
+	r.file, r.counter, r.writer = nil, nil, nil
+	if flushErr != nil {
+		return flushErr
+	}
+	return closeErr
+}
+
+func (r *captureRecorder) fail(err error) {
+	r.failed = true
+	verbose.Printf("Packet recording stopped: %v\n", err)
+	r.closeFile()
+}
+
+// Close flushes and closes the current capture file.
+func (r *captureRecorder) Close() {
+	if r == nil {
+		return
+	}
+	if err := r.closeFile(); err != nil {
+		verbose.Printf("Error closing capture file: %v\n", err)
+	}
+}
+
+// nextCapturePath returns path, or path with the first free _N suffix, so
+// rotated and repeated captures never overwrite earlier evidence.
+func nextCapturePath(path string) string {
+	ext := filepath.Ext(path)
+	name := path[:len(path)-len(ext)]
+	candidate := path
+	for count := 1; ; count++ {
+		if _, err := os.Stat(candidate); os.IsNotExist(err) {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s_%d%s", name, count, ext)
+	}
+}
+
+// packetSourceIP returns the IPv4 or IPv6 source address of a packet, or
+// nothing for non-IP frames and unspecified sources.
+func packetSourceIP(packet gopacket.Packet) string {
+	var src net.IP
+	if ipLayer := packet.Layer(layers.LayerTypeIPv4); ipLayer != nil {
+		src = ipLayer.(*layers.IPv4).SrcIP
+	} else if ipLayer := packet.Layer(layers.LayerTypeIPv6); ipLayer != nil {
+		src = ipLayer.(*layers.IPv6).SrcIP
+	}
+	if src == nil || src.IsUnspecified() {
+		return ""
+	}
+	return src.String()
+}
@@ -0,0 +1,149 @@
+package internal
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+)
+
+func buildPacket(t *testing.T, stack ...gopacket.SerializableLayer) gopacket.Packet {
+	t.Helper()
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, stack...); err != nil {
+		t.Fatal(err)
+	}
+	packet := gopacket.NewPacket(buf.Bytes(), layers.LayerTypeEthernet, gopacket.Default)
+	md := packet.Metadata()
+	md.Timestamp = time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	md.CaptureLength = len(buf.Bytes())
+	md.Length = len(buf.Bytes())
+	return packet
+}
+
+func TestPacketSourceIP(t *testing.T) {
+	mac := net.HardwareAddr{0x00, 0x50, 0x56, 0x00, 0x00, 0x01}
+	ipv4 := buildPacket(t,
+		&layers.Ethernet{SrcMAC: mac, DstMAC: broadcastMAC, EthernetType: layers.EthernetTypeIPv4},
+		&layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolUDP, SrcIP: net.ParseIP("192.0.2.10"), DstIP: net.ParseIP("192.0.2.1")},
+	)
+	ipv6 := buildPacket(t,
+		&layers.Ethernet{SrcMAC: mac, DstMAC: broadcastMAC, EthernetType: layers.EthernetTypeIPv6},
+		&layers.IPv6{Version: 6, HopLimit: 255, NextHeader: layers.IPProtocolNoNextHeader, SrcIP: net.ParseIP("fe80::250:56ff:fe00:1"), DstIP: net.ParseIP("ff02::1")},
+	)
+	dad := buildPacket(t,
+		&layers.Ethernet{SrcMAC: mac, DstMAC: broadcastMAC, EthernetType: layers.EthernetTypeIPv6},
+		&layers.IPv6{Version: 6, HopLimit: 255, NextHeader: layers.IPProtocolNoNextHeader, SrcIP: net.IPv6unspecified, DstIP: net.ParseIP("ff02::1")},
+	)
+	arp := buildPacket(t,
+		&layers.Ethernet{SrcMAC: mac, DstMAC: broadcastMAC, EthernetType: layers.EthernetTypeARP},
+		&layers.ARP{AddrType: layers.LinkTypeEthernet, Protocol: layers.EthernetTypeIPv4, HwAddressSize: 6, ProtAddressSize: 4,
+			Operation: layers.ARPRequest, SourceHwAddress: mac, SourceProtAddress: []byte{192, 0, 2, 10},
+			DstHwAddress: make([]byte, 6), DstProtAddress: []byte{192, 0, 2, 1}},
+	)
+
+	tests := []struct {
+		name   string
+		packet gopacket.Packet
+		want   string
+	}{
+		{"ipv4", ipv4, "192.0.2.10"},
+		{"ipv6", ipv6, "fe80::250:56ff:fe00:1"},
+		{"unspecified", dad, ""},
+		{"arp", arp, ""},
+	}
+	for _, tt := range tests {
+		if got := packetSourceIP(tt.packet); got != tt.want {
+			t.Errorf("%s: packetSourceIP = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCaptureSamplePerAsset(t *testing.T) {
+	savedAssets, savedDetails := discoveredAssets, hostDetails
+	t.Cleanup(func() { discoveredAssets, hostDetails = savedAssets, savedDetails })
+	discoveredAssets = []string{"192.0.2.10"}
+	hostDetails = make(map[string]*hostDetail)
+
+	asset := net.HardwareAddr{0x00, 0x50, 0x56, 0x00, 0x00, 0x01}
+	ndpHost := net.HardwareAddr{0x00, 0x50, 0x56, 0x00, 0x00, 0x02}
+	stranger := net.HardwareAddr{0x00, 0x50, 0x56, 0x00, 0x00, 0x03}
+	detailFor(ndpHost.String()).ipv6["fe80::250:56ff:fe00:2"] = struct{}{}
+
+	ipv4 := func(src net.HardwareAddr, ip string) gopacket.Packet {
+		return buildPacket(t,
+			&layers.Ethernet{SrcMAC: src, DstMAC: broadcastMAC, EthernetType: layers.EthernetTypeIPv4},
+			&layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolUDP, SrcIP: net.ParseIP(ip), DstIP: net.ParseIP("192.0.2.1")},
+		)
+	}
+	ipv6 := buildPacket(t,
+		&layers.Ethernet{SrcMAC: ndpHost, DstMAC: broadcastMAC, EthernetType: layers.EthernetTypeIPv6},
+		&layers.IPv6{Version: 6, HopLimit: 255, NextHeader: layers.IPProtocolNoNextHeader, SrcIP: net.ParseIP("fe80::250:56ff:fe00:2"), DstIP: net.ParseIP("ff02::1")},
+	)
+	lldp := func(src net.HardwareAddr) gopacket.Packet {
+		return buildPacket(t,
+			&layers.Ethernet{SrcMAC: src, DstMAC: net.HardwareAddr{0x01, 0x80, 0xc2, 0x00, 0x00, 0x0e}, EthernetType: layers.EthernetTypeLinkLayerDiscovery},
+			gopacket.Payload([]byte{0x00, 0x00}),
+		)
+	}
+	switchMAC := net.HardwareAddr{0x00, 0x50, 0x56, 0x00, 0x00, 0x04}
+	detailFor(switchMAC.String())
+
+	path := filepath.Join(t.TempDir(), "sample.pcapng")
+	recorder := newCaptureRecorder(CaptureOptions{Path: path, SamplePerAsset: 2}, layers.LinkTypeEthernet)
+	for range 3 {
+		recorder.Write(ipv4(asset, "192.0.2.10"))    // discovered asset
+		recorder.Write(ipv4(stranger, "192.0.2.99")) // never discovered
+		recorder.Write(ipv6)                         // found through NDP
+		recorder.Write(lldp(stranger))               // non-IP from an unknown MAC
+		recorder.Write(lldp(switchMAC))              // non-IP from a decoded device
+		recorder.Write(lldp(asset))                  // non-IP from the MAC of a discovered address
+	}
+	recorder.Close()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	reader, err := pcapgo.NewNgReader(file, pcapgo.DefaultNgReaderOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[string]int)
+	for {
+		data, _, err := reader.ReadPacketData()
+		if err != nil {
+			break
+		}
+		packet := gopacket.NewPacket(data, layers.LayerTypeEthernet, gopacket.Default)
+		src := packetSourceIP(packet)
+		if src == "" {
+			src = packetSourceMAC(packet)
+		}
+		counts[src]++
+	}
+
+	want := map[string]int{
+		"192.0.2.10":            2,
+		"fe80::250:56ff:fe00:2": 2,
+		switchMAC.String():      2,
+		asset.String():          2,
+		stranger.String():       0,
+	}
+	for src := range counts {
+		if want[src] == 0 {
+			t.Errorf("recorded %d packets from %s, want none", counts[src], src)
+		}
+	}
+	for src, n := range want {
+		if counts[src] != n {
+			t.Errorf("recorded %d packets from %s, want %d", counts[src], src, n)
+		}
+	}
+}
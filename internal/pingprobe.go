@@ -0,0 +1,188 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Naman1997/discovr/verbose"
+)
+
+// DefaultTCPPingPorts are probed by --tcp when no --tcp-ports are given.
+var DefaultTCPPingPorts = []int{22, 80, 443, 3389}
+
+// udpPingProbe is a UDP service whose well-formed request most hosts running
+// it will answer.
+type udpPingProbe struct {
+	port    int
+	payload []byte
+}
+
+var udpPingProbes = []udpPingProbe{
+	// DNS: standard query for the root NS records
+	{53, []byte{0x13, 0x37, 0x01, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0x00, 0x01}},
+	// NTP: version 3 client request
+	{123, append([]byte{0x1b}, make([]byte, 47)...)},
+	// SNMP: v2c GetRequest for sysDescr.0 with community "public"
+	{161, []byte{
+		0x30, 0x29, 0x02, 0x01, 0x01, 0x04, 0x06, 'p', 'u', 'b', 'l', 'i', 'c',
+		0xa0, 0x1c, 0x02, 0x04, 0x13, 0x37, 0x13, 0x37, 0x02, 0x01, 0x00, 0x02, 0x01, 0x00,
+		0x30, 0x0e, 0x30, 0x0c, 0x06, 0x08, 0x2b, 0x06, 0x01, 0x02, 0x01, 0x01, 0x01, 0x00, 0x05, 0x00,
+	}},
+}
+
+// probeResult is the first answer a probe got from a host.
+type probeResult struct {
+	method string
+	rtt    time.Duration
+}
+
+// ProbeScan finds live hosts with TCP connect and UDP probes, for networks
+// that drop ICMP and lie beyond the reach of ARP. A SYN-ACK or RST to any TCP
+// port, and any UDP reply or ICMP port unreachable, counts as alive. Hosts
+// already found by an ICMP sweep are not probed again.
+func ProbeScan(netiface *net.Interface, opts ActiveOptions) {
+	targets := pingTargets(netiface, opts)
+	if targets == nil {
+		return
+	}
+
+	found := make(map[string]bool)
+	mu.Lock()
+	for _, r := range Icmpscan_results {
+		found[r.IP] = true
+	}
+	mu.Unlock()
+
+	timeout := time.Duration(opts.TimeoutSec) * time.Second
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, max(opts.Concurrency, 1))
+	for ip := range targets.Addrs() {
+		target := ip.String()
+		if found[target] {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(target string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			result, ok := probeHost(target, opts, timeout)
+			if !ok {
+				return
+			}
+			verbose.VerbosePrintf("Host alive: %-15s (%s, RTT: %v)\n", target, result.method, result.rtt)
+			mu.Lock()
+			Icmpscan_results = append(Icmpscan_results, ScanResultICMP{
+				IP:     target,
+				RTT:    result.rtt,
+				Method: result.method,
+			})
+			mu.Unlock()
+		}(target)
+	}
+	wg.Wait()
+	verbose.VerbosePrintln("Probe sweep complete.")
+}
+
+// probeHost runs every enabled probe against target at once and returns the
+// first answer. The remaining probes are cancelled.
+func probeHost(target string, opts ActiveOptions, timeout time.Duration) (probeResult, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var probes []func() (probeResult, bool)
+	if opts.TCPPing {
+		for _, port := range opts.TCPPorts {
+			probes = append(probes, func() (probeResult, bool) { return tcpPing(ctx, target, port) })
+		}
+	}
+	if opts.UDPPing {
+		for _, probe := range udpPingProbes {
+			probes = append(probes, func() (probeResult, bool) { return udpPing(ctx, target, probe) })
+		}
+	}
+
+	answers := make(chan probeResult, len(probes))
+	var wg sync.WaitGroup
+	for _, probe := range probes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if result, ok := probe(); ok {
+				answers <- result
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(answers)
+	}()
+
+	result, ok := <-answers
+	return result, ok
+}
+
+// tcpPing connects to a TCP port. An accepted connection means the host sent
+// a SYN-ACK, a refused one that it answered with a RST.
+func tcpPing(ctx context.Context, target string, port int) (probeResult, bool) {
+	start := time.Now()
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(target, strconv.Itoa(port)))
+	rtt := time.Since(start)
+	if err == nil {
+		conn.Close()
+		return probeResult{method: fmt.Sprintf("tcp/%d syn-ack", port), rtt: rtt}, true
+	}
+	if isRefused(err) {
+		return probeResult{method: fmt.Sprintf("tcp/%d rst", port), rtt: rtt}, true
+	}
+	return probeResult{}, false
+}
+
+// udpPing sends a service request to a UDP port. A reply means the service is
+// running, an ICMP port unreachable (reported as a refused read on a connected
+// socket) that the host is up without it.
+func udpPing(ctx context.Context, target string, probe udpPingProbe) (probeResult, bool) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", net.JoinHostPort(target, strconv.Itoa(probe.port)))
+	if err != nil {
+		return probeResult{}, false
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	go func() {
+		<-ctx.Done()
+		conn.SetDeadline(time.Now())
+	}()
+
+	start := time.Now()
+	if _, err := conn.Write(probe.payload); err != nil {
+		return probeResult{}, false
+	}
+	buf := make([]byte, 512)
+	n, err := conn.Read(buf)
+	rtt := time.Since(start)
+	if err == nil && n > 0 {
+		return probeResult{method: fmt.Sprintf("udp/%d reply", probe.port), rtt: rtt}, true
+	}
+	if err != nil && isRefused(err) {
+		return probeResult{method: fmt.Sprintf("udp/%d unreachable", probe.port), rtt: rtt}, true
+	}
+	return probeResult{}, false
+}
+
+// isRefused reports whether err is a connection refused (TCP RST, or ICMP
+// port unreachable for UDP). Windows reports the latter as a reset.
+func isRefused(err error) bool {
+	return errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) ||
+		strings.Contains(err.Error(), "refused") || strings.Contains(err.Error(), "forcibly closed")
+}
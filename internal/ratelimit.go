@@ -0,0 +1,46 @@
+package internal
+
+import (
+	"sync"
+	"time"
+)
+
+// rateSlack is how far behind schedule a limiter may fall, after oversleeping,
+// before it stops catching up. It bounds the burst sent after a stall.
+const rateSlack = 10 * time.Millisecond
+
+// rateLimiter spaces packets evenly to cap the send rate of every goroutine
+// sharing it. A nil limiter never blocks.
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// newRateLimiter returns a limiter allowing pps packets per second, or nil
+// when pps is 0 (unlimited).
+func newRateLimiter(pps int) *rateLimiter {
+	if pps <= 0 {
+		return nil
+	}
+	return &rateLimiter{interval: time.Second / time.Duration(pps)}
+}
+
+// Wait blocks until the next packet may be sent. Slots are reserved ahead of
+// time, so sleeps longer than one interval are made up by the following calls.
+func (l *rateLimiter) Wait() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now.Add(-rateSlack)) {
+		l.next = now
+	}
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+}
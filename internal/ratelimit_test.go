@@ -0,0 +1,79 @@
+package internal
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewRateLimiter(t *testing.T) {
+	tests := []struct {
+		pps      int
+		interval time.Duration
+	}{
+		{0, 0},
+		{-1, 0},
+		{1, time.Second},
+		{1000, time.Millisecond},
+		{3, time.Second / 3},
+	}
+	for _, tt := range tests {
+		l := newRateLimiter(tt.pps)
+		switch {
+		case tt.interval == 0 && l != nil:
+			t.Errorf("newRateLimiter(%d) limits the rate, want unlimited", tt.pps)
+		case tt.interval != 0 && (l == nil || l.interval != tt.interval):
+			t.Errorf("newRateLimiter(%d) = %+v, want an interval of %v", tt.pps, l, tt.interval)
+		}
+	}
+
+	// A nil limiter never blocks
+	start := time.Now()
+	var l *rateLimiter
+	for range 1000 {
+		l.Wait()
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("an unlimited limiter waited %v", elapsed)
+	}
+}
+
+func TestRateLimiterShared(t *testing.T) {
+	const pps, workers, perWorker = 1000, 4, 25
+	l := newRateLimiter(pps)
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range perWorker {
+				l.Wait()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// The first packet goes out at once, the others one interval apart
+	elapsed := time.Since(start)
+	want := time.Duration(workers*perWorker-1) * time.Second / pps
+	if elapsed < want-rateSlack {
+		t.Errorf("%d packets at %d/s took %v, want at least %v", workers*perWorker, pps, elapsed, want)
+	}
+	if elapsed > 10*want {
+		t.Errorf("%d packets at %d/s took %v, want about %v", workers*perWorker, pps, elapsed, want)
+	}
+}
+
+func TestRateLimiterStall(t *testing.T) {
+	l := newRateLimiter(1000)
+	l.Wait()
+	time.Sleep(50 * time.Millisecond)
+
+	// After a stall the schedule restarts instead of bursting to catch up
+	l.Wait()
+	if behind := time.Since(l.next); behind > 0 {
+		t.Errorf("the next slot is %v in the past after a stall", behind)
+	}
+}
@@ -0,0 +1,262 @@
+package internal
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// Scope is the authorised scope of an engagement: the ranges that may be
+// scanned, the ranges that never may, and when. It is loaded from a scope
+// file such as
+//
+//	# ACME internal assessment
+//	allow    10.20.0.0/16, 10.30.5.10-10.30.5.50
+//	deny     10.20.99.0/24
+//	window   Mon-Fri 08:00-18:00
+//	period   2026-10-01 2026-10-31
+//	timezone Europe/Berlin
+//
+// allow and deny take the target format of ParseTargets, with CIDRs covering
+// their network and broadcast addresses. Without window or period lines
+// scanning is allowed at any time.
+type Scope struct {
+	Path     string
+	Digest   string // SHA-256 of the scope file, recorded in the audit log
+	allowed  *TargetSet
+	denied   *TargetSet
+	windows  []scopeWindow
+	periods  []scopePeriod
+	location *time.Location
+}
+
+// scopeWindow is a weekly time window. A window ending before it starts runs
+// past midnight into the next day.
+type scopeWindow struct {
+	spec       string
+	days       [7]bool
+	start, end int // minutes since midnight
+}
+
+// scopePeriod is a range of whole days, both included.
+type scopePeriod struct {
+	spec        string
+	first, last time.Time
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// LoadScope reads a scope file.
+func LoadScope(path string) (*Scope, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256(data)
+	scope := &Scope{Path: path, Digest: hex.EncodeToString(digest[:]), location: time.Local}
+
+	var allowed, denied []addrRange
+	type periodLine struct {
+		line int
+		spec string
+	}
+	var periodLines []periodLine
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for line := 1; scanner.Scan(); line++ {
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		keyword, rest := fields[0], strings.Join(fields[1:], " ")
+		switch strings.ToLower(keyword) {
+		case "allow", "deny":
+			var ranges []addrRange
+			parser := &targetParser{whole: true, resolve: true}
+			for _, entry := range targetEntries(rest) {
+				entryRanges, err := parser.entry(entry)
+				if err != nil {
+					return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+				}
+				ranges = append(ranges, entryRanges...)
+			}
+			if strings.EqualFold(keyword, "allow") {
+				allowed = append(allowed, ranges...)
+			} else {
+				denied = append(denied, ranges...)
+			}
+		case "window":
+			window, err := parseScopeWindow(rest)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+			}
+			scope.windows = append(scope.windows, window)
+		case "period":
+			// Dates are read once the time zone is known
+			periodLines = append(periodLines, periodLine{line, rest})
+		case "timezone":
+			location, err := time.LoadLocation(rest)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+			}
+			scope.location = location
+		default:
+			return nil, fmt.Errorf("%s:%d: unknown keyword %q, use allow, deny, window, period or timezone", path, line, keyword)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(allowed) == 0 {
+		return nil, fmt.Errorf("%s: no allow lines, nothing could be scanned", path)
+	}
+	scope.allowed = newTargetSet(allowed)
+	if len(denied) > 0 {
+		scope.denied = newTargetSet(denied)
+	}
+
+	for _, p := range periodLines {
+		period, err := parseScopePeriod(p.spec, scope.location)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, p.line, err)
+		}
+		scope.periods = append(scope.periods, period)
+	}
+	return scope, nil
+}
+
+// parseScopeWindow parses "Mon-Fri 08:00-18:00", "Sat,Sun 10:00-14:00" or
+// "daily 22:00-06:00".
+func parseScopeWindow(spec string) (scopeWindow, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != 2 {
+		return scopeWindow{}, fmt.Errorf("invalid window %q, use e.g. Mon-Fri 08:00-18:00", spec)
+	}
+	window := scopeWindow{spec: strings.Join(fields, " ")}
+
+	if days := strings.ToLower(fields[0]); days == "daily" || days == "*" {
+		window.days = [7]bool{true, true, true, true, true, true, true}
+	} else {
+		for _, part := range strings.Split(days, ",") {
+			from, to, isRange := strings.Cut(part, "-")
+			first, ok := weekdays[from]
+			last, ok2 := weekdays[to]
+			if !ok || (isRange && !ok2) {
+				return scopeWindow{}, fmt.Errorf("invalid days %q in window %q", fields[0], spec)
+			}
+			if !isRange {
+				last = first
+			}
+			for d := first; ; d = (d + 1) % 7 {
+				window.days[d] = true
+				if d == last {
+					break
+				}
+			}
+		}
+	}
+
+	start, end, ok := strings.Cut(fields[1], "-")
+	var err1, err2 error
+	window.start, err1 = parseClock(start)
+	window.end, err2 = parseClock(end)
+	if !ok || err1 != nil || err2 != nil || window.start == window.end {
+		return scopeWindow{}, fmt.Errorf("invalid hours %q in window %q", fields[1], spec)
+	}
+	return window, nil
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		if s == "24:00" {
+			return 24 * 60, nil
+		}
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// parseScopePeriod parses "2026-10-01 2026-10-31".
+func parseScopePeriod(spec string, location *time.Location) (scopePeriod, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != 2 {
+		return scopePeriod{}, fmt.Errorf("invalid period %q, use e.g. 2026-10-01 2026-10-31", spec)
+	}
+	first, err1 := time.ParseInLocation(time.DateOnly, fields[0], location)
+	last, err2 := time.ParseInLocation(time.DateOnly, fields[1], location)
+	if err1 != nil || err2 != nil || last.Before(first) {
+		return scopePeriod{}, fmt.Errorf("invalid period %q, use e.g. 2026-10-01 2026-10-31", spec)
+	}
+	return scopePeriod{spec: strings.Join(fields, " "), first: first, last: last.AddDate(0, 0, 1)}, nil
+}
+
+// contains reports whether t, in the scope's time zone, falls in the window.
+func (w scopeWindow) contains(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	day := t.Weekday()
+	if w.start < w.end {
+		return w.days[day] && minute >= w.start && minute < w.end
+	}
+	yesterday := (day + 6) % 7
+	return (w.days[day] && minute >= w.start) || (w.days[yesterday] && minute < w.end)
+}
+
+// Allows reports whether ip is in the allowed ranges and not in a denied one.
+// A nil scope allows every address.
+func (s *Scope) Allows(ip net.IP) bool {
+	if s == nil {
+		return true
+	}
+	return s.allowed.Contains(ip) && !s.denied.Contains(ip)
+}
+
+// Check returns why scanning targets at now is out of scope, or nothing if
+// it is allowed.
+func (s *Scope) Check(targets *TargetSet, now time.Time) []string {
+	var violations []string
+	if targets.Empty() {
+		return violations
+	}
+	if outside := targets.Subtract(s.allowed); !outside.Empty() {
+		violations = append(violations, fmt.Sprintf("%d addresses are outside the allowed ranges: %v", outside.Count(), outside))
+	}
+	if forbidden := targets.Intersect(s.denied); !forbidden.Empty() {
+		violations = append(violations, fmt.Sprintf("%d addresses are in denied ranges: %v", forbidden.Count(), forbidden))
+	}
+
+	now = now.In(s.location)
+	if len(s.periods) > 0 {
+		var inPeriod bool
+		var specs []string
+		for _, p := range s.periods {
+			inPeriod = inPeriod || (!now.Before(p.first) && now.Before(p.last))
+			specs = append(specs, p.spec)
+		}
+		if !inPeriod {
+			violations = append(violations, fmt.Sprintf("%s is outside the engagement period (%s)",
+				now.Format("2006-01-02 15:04 MST"), strings.Join(specs, "; ")))
+		}
+	}
+	if len(s.windows) > 0 {
+		var inWindow bool
+		var specs []string
+		for _, w := range s.windows {
+			inWindow = inWindow || w.contains(now)
+			specs = append(specs, w.spec)
+		}
+		if !inWindow {
+			violations = append(violations, fmt.Sprintf("%s is outside the allowed windows (%s)",
+				now.Format("Mon 15:04 MST"), strings.Join(specs, "; ")))
+		}
+	}
+	return violations
+}
@@ -0,0 +1,123 @@
+package internal
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeScope(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "acme.scope")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadScopeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"no allow", "deny 10.0.0.0/8\n", "no allow lines"},
+		{"unknown keyword", "allow 10.0.0.0/8\npermit 10.1.0.0/16\n", `:2: unknown keyword "permit"`},
+		{"bad target", "allow 10.0.0.0/33\n", ":1: invalid target"},
+		{"bad days", "allow 10.0.0.0/8\nwindow Mon-Fry 08:00-18:00\n", `:2: invalid days "Mon-Fry"`},
+		{"bad hours", "allow 10.0.0.0/8\nwindow daily 08:00-08:00\n", `:2: invalid hours`},
+		{"bad period", "allow 10.0.0.0/8\nperiod 2026-10-31 2026-10-01\n", `:2: invalid period`},
+		{"bad timezone", "allow 10.0.0.0/8\ntimezone Mars/Olympus\n", ":2:"},
+	}
+	for _, tt := range tests {
+		_, err := LoadScope(writeScope(t, tt.content))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error %v, want one containing %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestScopeCheck(t *testing.T) {
+	scope, err := LoadScope(writeScope(t, `# ACME internal assessment
+allow    10.20.0.0/16, 10.30.5.10-10.30.5.50
+deny	10.20.99.0/24   # payment network
+window   Mon-Fri 08:00-18:00
+window   Sat 22:00-02:00
+period   2026-10-01 2026-10-31
+timezone Europe/Berlin
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("no time zone database:", err)
+	}
+	monday := time.Date(2026, 10, 19, 10, 0, 0, 0, berlin)
+
+	tests := []struct {
+		name    string
+		targets string
+		now     time.Time
+		want    []string // substrings of each violation, in order
+	}{
+		{"allowed", "10.20.1.0/24,10.30.5.10-20", monday, nil},
+		{"allowed in another time zone", "10.20.1.1", time.Date(2026, 10, 19, 8, 30, 0, 0, time.UTC), nil},
+		{"outside", "10.30.5.1-10.30.5.60", monday, []string{"19 addresses are outside the allowed ranges: 10.30.5.1-10.30.5.9, 10.30.5.51-10.30.5.60"}},
+		{"denied", "10.20.99.5,10.20.1.1", monday, []string{"1 addresses are in denied ranges: 10.20.99.5"}},
+		{"before hours", "10.20.1.1", time.Date(2026, 10, 19, 7, 59, 0, 0, berlin), []string{"Mon 07:59 CEST is outside the allowed windows (Mon-Fri 08:00-18:00; Sat 22:00-02:00)"}},
+		{"end of window", "10.20.1.1", time.Date(2026, 10, 19, 18, 0, 0, 0, berlin), []string{"outside the allowed windows"}},
+		{"overnight window", "10.20.1.1", time.Date(2026, 10, 25, 1, 30, 0, 0, berlin), nil},
+		{"after overnight window", "10.20.1.1", time.Date(2026, 10, 25, 2, 30, 0, 0, berlin), []string{"Sun 02:30 CET is outside the allowed windows"}},
+		{"last day of period", "10.20.1.1", time.Date(2026, 10, 30, 17, 59, 0, 0, berlin), nil},
+		{"after period", "10.20.1.1", time.Date(2026, 11, 2, 10, 0, 0, 0, berlin), []string{"2026-11-02 10:00 CET is outside the engagement period (2026-10-01 2026-10-31)"}},
+		{"everything", "10.20.99.1,192.0.2.1", time.Date(2026, 9, 26, 12, 0, 0, 0, berlin), []string{"outside the allowed ranges: 192.0.2.1", "in denied ranges: 10.20.99.1", "outside the engagement period", "outside the allowed windows"}},
+		{"nothing to scan", "", time.Date(2026, 9, 26, 12, 0, 0, 0, berlin), nil},
+	}
+	for _, tt := range tests {
+		targets, err := ParseTargets(tt.targets)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := scope.Check(targets, tt.now)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: violations %q, want %d", tt.name, got, len(tt.want))
+			continue
+		}
+		for i := range got {
+			if !strings.Contains(got[i], tt.want[i]) {
+				t.Errorf("%s: violation %q, want it to contain %q", tt.name, got[i], tt.want[i])
+			}
+		}
+	}
+}
+
+func TestScopeAllows(t *testing.T) {
+	scope, err := LoadScope(writeScope(t, "allow 10.20.0.0/16, fe80::/64\ndeny 10.20.99.0/24\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"10.20.0.0", true},
+		{"10.20.1.1", true},
+		{"10.20.99.1", false},
+		{"10.21.0.1", false},
+		{"fe80::1", true},
+		{"2001:db8::1", false},
+	}
+	for _, tt := range tests {
+		if got := scope.Allows(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("Allows(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+	var none *Scope
+	if !none.Allows(net.ParseIP("192.0.2.1")) {
+		t.Error("a nil scope must allow every address")
+	}
+}
@@ -0,0 +1,485 @@
+package internal
+
+import (
+	"bufio"
+	"fmt"
+	"iter"
+	"net"
+	"net/netip"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Naman1997/discovr/verbose"
+)
+
+// maxTargets caps a target list at the size of a /8.
+const maxTargets = 1 << 24
+
+var hostnameRegex = regexp.MustCompile(`^([A-Za-z0-9]([A-Za-z0-9-]{0,61}[A-Za-z0-9])?\.)*[A-Za-z0-9]([A-Za-z0-9-]{0,61}[A-Za-z0-9])?\.?$`)
+
+// TargetSet is a de-duplicated set of IPv4 and IPv6 addresses, kept as
+// sorted, non-overlapping ranges so large networks cost no memory. A nil
+// TargetSet means no targets were given.
+type TargetSet struct {
+	ranges []addrRange
+}
+
+type addrRange struct {
+	first, last netip.Addr
+}
+
+// ParseTargets parses target specifications shared by every scanner. Each
+// spec is a comma or space separated list of
+//
+//	IP addresses          10.0.0.5, 2001:db8::1
+//	CIDRs                 10.0.0.0/24 (without its network and broadcast addresses)
+//	dash ranges           10.0.0.5-10.0.0.40 or 10.0.0.5-40
+//	hostnames             printer.example.com (every address it resolves to)
+//	target files          @targets.txt (one list per line, # starts a comment)
+//
+// It returns nil if the specs are empty.
+func ParseTargets(specs ...string) (*TargetSet, error) {
+	return (&targetParser{resolve: true}).parse(specs)
+}
+
+// ParseExclusions parses targets to leave out of a scan, in the format of
+// ParseTargets. CIDRs are excluded whole, network and broadcast included.
+func ParseExclusions(specs ...string) (*TargetSet, error) {
+	return (&targetParser{whole: true, resolve: true}).parse(specs)
+}
+
+// ParseTargetsOffline parses targets like ParseTargets without any DNS
+// lookups. Hostnames are returned as they are instead.
+func ParseTargetsOffline(specs ...string) (*TargetSet, []string, error) {
+	parser := &targetParser{}
+	set, err := parser.parse(specs)
+	if err == nil && set == nil && len(parser.unresolved) > 0 {
+		// Only hostnames: an empty set, not a sweep of the interface networks
+		set = &TargetSet{}
+	}
+	return set, parser.unresolved, err
+}
+
+// ParseExclusionsOffline parses exclusions like ParseExclusions without any
+// DNS lookups. Hostnames are returned as they are instead.
+func ParseExclusionsOffline(specs ...string) (*TargetSet, []string, error) {
+	parser := &targetParser{whole: true}
+	set, err := parser.parse(specs)
+	return set, parser.unresolved, err
+}
+
+// ValidateTargets checks the syntax of a target specification without
+// resolving hostnames, for input forms. It reports the same errors as
+// ParseTargets.
+func ValidateTargets(spec string) error {
+	_, err := (&targetParser{}).parse([]string{spec})
+	return err
+}
+
+// targetParser holds the settings of one parse and the hostnames it left
+// unresolved.
+type targetParser struct {
+	whole      bool // CIDRs keep their network and broadcast addresses
+	resolve    bool // hostnames are looked up, or else collected in unresolved
+	unresolved []string
+}
+
+func (p *targetParser) parse(specs []string) (*TargetSet, error) {
+	var ranges []addrRange
+	for _, spec := range specs {
+		for _, entry := range targetEntries(spec) {
+			entryRanges, err := p.entry(entry)
+			if err != nil {
+				return nil, err
+			}
+			ranges = append(ranges, entryRanges...)
+		}
+	}
+	if len(ranges) == 0 {
+		return nil, nil
+	}
+	set := newTargetSet(ranges)
+	if !p.whole && set.Count() > maxTargets {
+		return nil, fmt.Errorf("targets cover more than %d addresses, split the scan into /8 or smaller ranges", maxTargets)
+	}
+	return set, nil
+}
+
+func targetEntries(spec string) []string {
+	return strings.FieldsFunc(spec, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+	})
+}
+
+func (p *targetParser) entry(entry string) ([]addrRange, error) {
+	if path, ok := strings.CutPrefix(entry, "@"); ok {
+		return p.file(path)
+	}
+
+	if prefix, err := netip.ParsePrefix(entry); err == nil {
+		prefix = prefix.Masked()
+		r := addrRange{prefix.Addr(), prefixLast(prefix)}
+		// Like a sweep of a local subnet, skip the network and broadcast addresses
+		if !p.whole && prefix.Addr().Is4() && prefix.Bits() < 31 {
+			r = addrRange{r.first.Next(), r.last.Prev()}
+		}
+		return []addrRange{r}, nil
+	} else if strings.Contains(entry, "/") {
+		return nil, fmt.Errorf("invalid target %q: not a valid CIDR", entry)
+	}
+
+	if start, end, ok := strings.Cut(entry, "-"); ok {
+		if first, err := netip.ParseAddr(start); err == nil {
+			last, err := netip.ParseAddr(end)
+			if err != nil && first.Is4() {
+				// Short form 10.0.0.5-40 replaces the last octet
+				if octet, convErr := strconv.Atoi(end); convErr == nil && octet >= 0 && octet <= 255 {
+					b := first.As4()
+					b[3] = byte(octet)
+					last, err = netip.AddrFrom4(b), nil
+				}
+			}
+			if err != nil || first.Is4() != last.Is4() {
+				return nil, fmt.Errorf("invalid target range %q", entry)
+			}
+			if last.Less(first) {
+				return nil, fmt.Errorf("invalid target range %q: end is before start", entry)
+			}
+			return []addrRange{{first.Unmap(), last.Unmap()}}, nil
+		}
+	}
+
+	if addr, err := netip.ParseAddr(entry); err == nil {
+		addr = addr.Unmap()
+		return []addrRange{{addr, addr}}, nil
+	}
+	if strings.Trim(entry, "0123456789.") == "" || strings.Contains(entry, ":") {
+		return nil, fmt.Errorf("invalid target %q: not a valid IP address", entry)
+	}
+	if len(entry) > 253 || !hostnameRegex.MatchString(entry) {
+		return nil, fmt.Errorf("invalid target %q: not an IP address, CIDR, range or hostname", entry)
+	}
+	if !p.resolve {
+		if !slices.Contains(p.unresolved, entry) {
+			p.unresolved = append(p.unresolved, entry)
+		}
+		return nil, nil
+	}
+
+	ips, err := net.LookupIP(entry)
+	if err != nil || len(ips) == 0 {
+		return nil, fmt.Errorf("cannot resolve target %q", entry)
+	}
+	var ranges []addrRange
+	for _, ip := range ips {
+		if addr, ok := netip.AddrFromSlice(ip); ok {
+			addr = addr.Unmap()
+			verbose.VerbosePrintf("Target %s resolves to %s\n", entry, addr)
+			ranges = append(ranges, addrRange{addr, addr})
+		}
+	}
+	return ranges, nil
+}
+
+func (p *targetParser) file(path string) ([]addrRange, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading target file %s: %w", path, err)
+	}
+	defer file.Close()
+
+	var ranges []addrRange
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		for _, entry := range targetEntries(text) {
+			if strings.HasPrefix(entry, "@") {
+				return nil, fmt.Errorf("%s:%d: target files cannot include other files", path, line)
+			}
+			entryRanges, err := p.entry(entry)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+			}
+			ranges = append(ranges, entryRanges...)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading target file %s: %w", path, err)
+	}
+	return ranges, nil
+}
+
+// newTargetSet sorts ranges and merges the ones that overlap or touch.
+func newTargetSet(ranges []addrRange) *TargetSet {
+	slices.SortFunc(ranges, func(a, b addrRange) int { return a.first.Compare(b.first) })
+	var merged []addrRange
+	for _, r := range ranges {
+		if n := len(merged); n > 0 {
+			prev := &merged[n-1]
+			if !prev.last.Less(r.first) || prev.last.Next() == r.first {
+				if prev.last.Less(r.last) {
+					prev.last = r.last
+				}
+				continue
+			}
+		}
+		merged = append(merged, r)
+	}
+	return &TargetSet{ranges: merged}
+}
+
+// networkTargets is the set of host addresses of a local subnet: every
+// address but the network and broadcast ones, unless it is a /31 or /32.
+func networkTargets(n *net.IPNet) *TargetSet {
+	ones, _ := n.Mask.Size()
+	addr, ok := netip.AddrFromSlice(n.IP)
+	if !ok {
+		return nil
+	}
+	ranges, _ := (&targetParser{}).entry(netip.PrefixFrom(addr.Unmap(), ones).String())
+	return &TargetSet{ranges: ranges}
+}
+
+// Empty reports whether the set has no addresses.
+func (t *TargetSet) Empty() bool {
+	return t == nil || len(t.ranges) == 0
+}
+
+// Count returns the number of addresses in the set, saturating at the
+// largest uint64.
+func (t *TargetSet) Count() uint64 {
+	if t == nil {
+		return 0
+	}
+	var total uint64
+	for _, r := range t.ranges {
+		size := rangeSize(r)
+		if total+size < total {
+			return ^uint64(0)
+		}
+		total += size
+	}
+	return total
+}
+
+// Contains reports whether ip is in the set.
+func (t *TargetSet) Contains(ip net.IP) bool {
+	if t == nil {
+		return false
+	}
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return false
+	}
+	addr = addr.Unmap()
+	i, _ := slices.BinarySearchFunc(t.ranges, addr, func(r addrRange, a netip.Addr) int {
+		if r.last.Less(a) {
+			return -1
+		}
+		if a.Less(r.first) {
+			return 1
+		}
+		return 0
+	})
+	return i < len(t.ranges) && !addr.Less(t.ranges[i].first) && !t.ranges[i].last.Less(addr)
+}
+
+// Addrs streams the addresses of the set in ascending order. IPv4 addresses
+// are 4 bytes long.
+func (t *TargetSet) Addrs() iter.Seq[net.IP] {
+	return func(yield func(net.IP) bool) {
+		if t == nil {
+			return
+		}
+		for _, r := range t.ranges {
+			for addr := r.first; ; addr = addr.Next() {
+				if !yield(net.IP(addr.AsSlice())) {
+					return
+				}
+				if addr == r.last {
+					break
+				}
+			}
+		}
+	}
+}
+
+// unionTargets merges sets into one.
+func unionTargets(sets ...*TargetSet) *TargetSet {
+	var ranges []addrRange
+	for _, set := range sets {
+		if set != nil {
+			ranges = append(ranges, set.ranges...)
+		}
+	}
+	return newTargetSet(ranges)
+}
+
+// Intersect returns the addresses that are in both sets.
+func (t *TargetSet) Intersect(other *TargetSet) *TargetSet {
+	if t.Empty() || other.Empty() {
+		return &TargetSet{}
+	}
+	var out []addrRange
+	i, j := 0, 0
+	for i < len(t.ranges) && j < len(other.ranges) {
+		a, b := t.ranges[i], other.ranges[j]
+		first, last := a.first, a.last
+		if first.Less(b.first) {
+			first = b.first
+		}
+		if b.last.Less(last) {
+			last = b.last
+		}
+		if !last.Less(first) {
+			out = append(out, addrRange{first, last})
+		}
+		if a.last.Less(b.last) {
+			i++
+		} else {
+			j++
+		}
+	}
+	return &TargetSet{ranges: out}
+}
+
+// Subtract returns the addresses of t that are not in excluded.
+func (t *TargetSet) Subtract(excluded *TargetSet) *TargetSet {
+	if t == nil || excluded.Empty() {
+		return t
+	}
+	var out []addrRange
+	for _, r := range t.ranges {
+		for _, x := range excluded.ranges {
+			if x.last.Less(r.first) || r.last.Less(x.first) {
+				continue
+			}
+			if r.first.Less(x.first) {
+				out = append(out, addrRange{r.first, x.first.Prev()})
+			}
+			if !x.last.Less(r.last) {
+				r.first = netip.Addr{}
+				break
+			}
+			r.first = x.last.Next()
+		}
+		if r.first.IsValid() {
+			out = append(out, r)
+		}
+	}
+	return &TargetSet{ranges: out}
+}
+
+// IPv4 returns the IPv4 addresses of the set.
+func (t *TargetSet) IPv4() *TargetSet {
+	return t.family(true)
+}
+
+// IPv6 returns the IPv6 addresses of the set.
+func (t *TargetSet) IPv6() *TargetSet {
+	return t.family(false)
+}
+
+func (t *TargetSet) family(is4 bool) *TargetSet {
+	if t == nil {
+		return &TargetSet{}
+	}
+	var out []addrRange
+	for _, r := range t.ranges {
+		if r.first.Is4() == is4 {
+			out = append(out, r)
+		}
+	}
+	return &TargetSet{ranges: out}
+}
+
+// CIDRs lists the set as the fewest IP addresses and CIDRs that cover it
+// exactly, for tools such as nmap that do not take full dash ranges.
+func (t *TargetSet) CIDRs() []string {
+	var out []string
+	if t == nil {
+		return out
+	}
+	for _, r := range t.ranges {
+		for addr := r.first; ; {
+			prefix := netip.PrefixFrom(addr, addr.BitLen())
+			for bits := addr.BitLen() - 1; bits >= 0; bits-- {
+				wider := netip.PrefixFrom(addr, bits)
+				if wider.Masked().Addr() != addr || r.last.Less(prefixLast(wider)) {
+					break
+				}
+				prefix = wider
+			}
+			if prefix.IsSingleIP() {
+				out = append(out, addr.String())
+			} else {
+				out = append(out, prefix.String())
+			}
+			last := prefixLast(prefix)
+			if last == r.last {
+				break
+			}
+			addr = last.Next()
+		}
+	}
+	return out
+}
+
+// String summarises the set for logs and messages. Only the first five ranges
+// are listed, use CIDRs for the full set.
+func (t *TargetSet) String() string {
+	if t == nil {
+		return ""
+	}
+	var parts []string
+	for i, r := range t.ranges {
+		if i == 5 {
+			parts = append(parts, fmt.Sprintf("and %d more", len(t.ranges)-5))
+			break
+		}
+		if r.first == r.last {
+			parts = append(parts, r.first.String())
+		} else {
+			parts = append(parts, r.first.String()+"-"+r.last.String())
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// prefixLast returns the last address of a prefix.
+func prefixLast(p netip.Prefix) netip.Addr {
+	b := p.Masked().Addr().As16()
+	hostBits := p.Addr().BitLen() - p.Bits()
+	for i := 15; i >= 0 && hostBits > 0; i-- {
+		n := min(hostBits, 8)
+		b[i] |= byte(1<<n - 1)
+		hostBits -= n
+	}
+	last := netip.AddrFrom16(b)
+	if p.Addr().Is4() {
+		return last.Unmap()
+	}
+	return last
+}
+
+// rangeSize returns the number of addresses in r, saturating at the largest uint64.
+func rangeSize(r addrRange) uint64 {
+	a, b := r.first.As16(), r.last.As16()
+	var hiA, loA, hiB, loB uint64
+	for i := 0; i < 8; i++ {
+		hiA, hiB = hiA<<8|uint64(a[i]), hiB<<8|uint64(b[i])
+		loA, loB = loA<<8|uint64(a[i+8]), loB<<8|uint64(b[i+8])
+	}
+	hi := hiB - hiA
+	lo := loB - loA
+	if loB < loA {
+		hi--
+	}
+	if hi > 0 || lo == ^uint64(0) {
+		return ^uint64(0)
+	}
+	return lo + 1
+}
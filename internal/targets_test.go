@@ -0,0 +1,274 @@
+package internal
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func mustTargets(t *testing.T, specs ...string) *TargetSet {
+	t.Helper()
+	set, err := ParseExclusions(specs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return set
+}
+
+func TestParseTargets(t *testing.T) {
+	tests := []struct {
+		spec  string
+		want  string
+		count uint64
+	}{
+		{"10.0.0.5", "10.0.0.5", 1},
+		{"10.0.0.5-10.0.0.40", "10.0.0.5-10.0.0.40", 36},
+		{"10.0.0.5-40", "10.0.0.5-10.0.0.40", 36},
+		{"10.0.0.0/24", "10.0.0.1-10.0.0.254", 254},
+		{"10.0.0.7/24", "10.0.0.1-10.0.0.254", 254},
+		{"10.0.0.0/31", "10.0.0.0-10.0.0.1", 2},
+		{"10.0.0.9/32", "10.0.0.9", 1},
+		{"2001:db8::/126", "2001:db8::-2001:db8::3", 4},
+		{"2001:db8::1-2001:db8::2", "2001:db8::1-2001:db8::2", 2},
+		{"::ffff:10.0.0.1", "10.0.0.1", 1},
+		{"10.0.0.1, 10.0.0.2 10.0.0.3\t10.0.0.9", "10.0.0.1-10.0.0.3, 10.0.0.9", 4},
+		{"10.0.0.0/29,10.0.0.4-20", "10.0.0.1-10.0.0.20", 20},
+		{"10.0.0.1,10.0.0.3,10.0.0.5,10.0.0.7,10.0.0.9,10.0.0.11,10.0.0.13", "10.0.0.1, 10.0.0.3, 10.0.0.5, 10.0.0.7, 10.0.0.9, and 2 more", 7},
+		{"10.0.0.0/8", "10.0.0.1-10.255.255.254", 1<<24 - 2},
+	}
+	for _, tt := range tests {
+		set, err := ParseTargets(tt.spec)
+		if err != nil {
+			t.Errorf("ParseTargets(%q): %v", tt.spec, err)
+			continue
+		}
+		if got := set.String(); got != tt.want {
+			t.Errorf("ParseTargets(%q) = %s, want %s", tt.spec, got, tt.want)
+		}
+		if got := set.Count(); got != tt.count {
+			t.Errorf("ParseTargets(%q) has %d addresses, want %d", tt.spec, got, tt.count)
+		}
+	}
+
+	if set, err := ParseTargets("", " , "); set != nil || err != nil {
+		t.Errorf("ParseTargets of empty specs = %v, %v, want nil", set, err)
+	}
+}
+
+func TestParseTargetsErrors(t *testing.T) {
+	tests := []struct {
+		spec string
+		want string
+	}{
+		{"10.0.0.0/33", "not a valid CIDR"},
+		{"10.0.0.9-10.0.0.1", "end is before start"},
+		{"10.0.0.5-300", "invalid target range"},
+		{"10.0.0.1-2001:db8::1", "invalid target range"},
+		{"10.0.0.256", "not a valid IP address"},
+		{"2001:db8::zz", "not a valid IP address"},
+		{"bad_host!", "not an IP address, CIDR, range or hostname"},
+		{"10.0.0.0/7", "more than 16777216 addresses"},
+		{"10.0.0.0/8,11.0.0.1-3", "more than 16777216 addresses"},
+		{"@" + filepath.Join(os.TempDir(), "discovr-no-such-file"), "reading target file"},
+	}
+	for _, tt := range tests {
+		_, err := ParseTargets(tt.spec)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ParseTargets(%q) error = %v, want %q", tt.spec, err, tt.want)
+		}
+	}
+}
+
+func TestParseTargetsFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "targets.txt")
+	content := "# lab network\n10.0.0.1-3  # switches\n\n10.0.0.10, printer.example.com\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	set, hostnames, err := ParseTargetsOffline("@"+path, "10.0.0.4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := set.String(), "10.0.0.1-10.0.0.4, 10.0.0.10"; got != want {
+		t.Errorf("targets = %s, want %s", got, want)
+	}
+	if !slices.Equal(hostnames, []string{"printer.example.com"}) {
+		t.Errorf("hostnames = %v", hostnames)
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"nested file", "10.0.0.1\n@other.txt\n", "bad.txt:2: target files cannot include other files"},
+		{"bad entry", "10.0.0.1\n\n10.0.0.300\n", "bad.txt:3: invalid target"},
+	}
+	for _, tt := range tests {
+		bad := filepath.Join(dir, "bad.txt")
+		if err := os.WriteFile(bad, []byte(tt.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ParseTargets("@" + bad); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestParseTargetsOffline(t *testing.T) {
+	tests := []struct {
+		spec      string
+		want      string
+		hostnames []string
+	}{
+		{"10.0.0.1", "10.0.0.1", nil},
+		{"a.example,10.0.0.1,b.example.,a.example", "10.0.0.1", []string{"a.example", "b.example."}},
+		{"a.example", "", []string{"a.example"}},
+	}
+	for _, tt := range tests {
+		set, hostnames, err := ParseTargetsOffline(tt.spec)
+		if err != nil {
+			t.Errorf("ParseTargetsOffline(%q): %v", tt.spec, err)
+			continue
+		}
+		if set == nil {
+			t.Errorf("ParseTargetsOffline(%q) returned no set", tt.spec)
+		} else if got := set.String(); got != tt.want {
+			t.Errorf("ParseTargetsOffline(%q) = %s, want %s", tt.spec, got, tt.want)
+		}
+		if !slices.Equal(hostnames, tt.hostnames) {
+			t.Errorf("ParseTargetsOffline(%q) hostnames = %v, want %v", tt.spec, hostnames, tt.hostnames)
+		}
+	}
+}
+
+func TestParseExclusions(t *testing.T) {
+	// Exclusions keep the network and broadcast addresses and have no size limit
+	tests := []struct {
+		spec  string
+		want  string
+		count uint64
+	}{
+		{"10.0.0.0/24", "10.0.0.0-10.0.0.255", 256},
+		{"10.0.0.0/7", "10.0.0.0-11.255.255.255", 1 << 25},
+		{"2001:db8::/64", "2001:db8::-2001:db8::ffff:ffff:ffff:ffff", ^uint64(0)}, // saturated
+	}
+	for _, tt := range tests {
+		set := mustTargets(t, tt.spec)
+		if got := set.String(); got != tt.want {
+			t.Errorf("ParseExclusions(%q) = %s, want %s", tt.spec, got, tt.want)
+		}
+		if got := set.Count(); got != tt.count {
+			t.Errorf("ParseExclusions(%q) has %d addresses, want %d", tt.spec, got, tt.count)
+		}
+	}
+}
+
+func TestTargetSetOperations(t *testing.T) {
+	tests := []struct {
+		a, b      string
+		subtract  string
+		intersect string
+	}{
+		{"10.0.0.1-10.0.0.20", "10.0.0.5", "10.0.0.1-10.0.0.4, 10.0.0.6-10.0.0.20", "10.0.0.5"},
+		{"10.0.0.1-10.0.0.20", "10.0.0.1-10.0.0.20", "", "10.0.0.1-10.0.0.20"},
+		{"10.0.0.1-10.0.0.20", "10.0.0.0/29,10.0.0.16/30", "10.0.0.8-10.0.0.15, 10.0.0.20", "10.0.0.1-10.0.0.7, 10.0.0.16-10.0.0.19"},
+		{"10.0.0.1-10.0.0.20", "192.0.2.0/24", "10.0.0.1-10.0.0.20", ""},
+		{"10.0.0.1,2001:db8::1-2001:db8::3", "2001:db8::2", "10.0.0.1, 2001:db8::1, 2001:db8::3", "2001:db8::2"},
+	}
+	for _, tt := range tests {
+		a, b := mustTargets(t, tt.a), mustTargets(t, tt.b)
+		if got := a.Subtract(b).String(); got != tt.subtract {
+			t.Errorf("%s minus %s = %s, want %s", tt.a, tt.b, got, tt.subtract)
+		}
+		if got := a.Intersect(b).String(); got != tt.intersect {
+			t.Errorf("%s and %s = %s, want %s", tt.a, tt.b, got, tt.intersect)
+		}
+		if got := b.Intersect(a).String(); got != tt.intersect {
+			t.Errorf("%s and %s = %s, want %s", tt.b, tt.a, got, tt.intersect)
+		}
+	}
+
+	var none *TargetSet
+	set := mustTargets(t, "10.0.0.1")
+	if set.Subtract(none) != set || none.Subtract(set) != nil {
+		t.Error("Subtract with a nil set changed the targets")
+	}
+	if !set.Intersect(none).Empty() || !none.Intersect(set).Empty() {
+		t.Error("Intersect with a nil set is not empty")
+	}
+}
+
+func TestTargetSetContains(t *testing.T) {
+	set := mustTargets(t, "10.0.0.8-10.0.0.15,10.0.1.1,2001:db8::/127")
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"10.0.0.7", false},
+		{"10.0.0.8", true},
+		{"10.0.0.12", true},
+		{"10.0.0.15", true},
+		{"10.0.0.16", false},
+		{"10.0.1.1", true},
+		{"::ffff:10.0.1.1", true},
+		{"2001:db8::1", true},
+		{"2001:db8::2", false},
+	}
+	for _, tt := range tests {
+		if got := set.Contains(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("Contains(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+	if (*TargetSet)(nil).Contains(net.ParseIP("10.0.0.8")) {
+		t.Error("a nil set contains an address")
+	}
+}
+
+func TestTargetSetCIDRs(t *testing.T) {
+	tests := []struct {
+		spec string
+		want []string
+	}{
+		{"10.0.0.0/24", []string{"10.0.0.1", "10.0.0.2/31", "10.0.0.4/30", "10.0.0.8/29", "10.0.0.16/28", "10.0.0.32/27", "10.0.0.64/26", "10.0.0.128/26", "10.0.0.192/27", "10.0.0.224/28", "10.0.0.240/29", "10.0.0.248/30", "10.0.0.252/31", "10.0.0.254"}},
+		{"10.0.0.5-12", []string{"10.0.0.5", "10.0.0.6/31", "10.0.0.8/30", "10.0.0.12"}},
+		{"10.0.0.9", []string{"10.0.0.9"}},
+		{"2001:db8::/64", []string{"2001:db8::/64"}},
+		{"255.255.255.254-255.255.255.255", []string{"255.255.255.254/31"}},
+	}
+	for _, tt := range tests {
+		set, err := ParseTargets(tt.spec)
+		if strings.HasPrefix(tt.spec, "2001:db8::/") {
+			// Too large for ParseTargets
+			set, err = ParseExclusions(tt.spec)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := set.CIDRs()
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("CIDRs(%s) = %v, want %v", tt.spec, got, tt.want)
+		}
+		// The CIDRs cover exactly the set
+		if back := mustTargets(t, strings.Join(got, ",")); back.String() != set.String() {
+			t.Errorf("CIDRs(%s) cover %s", tt.spec, back)
+		}
+	}
+}
+
+func TestTargetSetAddrs(t *testing.T) {
+	set := mustTargets(t, "10.0.0.254-10.0.1.1,2001:db8::ff")
+	var got []string
+	for ip := range set.Addrs() {
+		got = append(got, fmt.Sprintf("%s/%d", ip, len(ip)))
+	}
+	want := []string{"10.0.0.254/4", "10.0.0.255/4", "10.0.1.0/4", "10.0.1.1/4", "2001:db8::ff/16"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Addrs = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,610 @@
+package internal
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Naman1997/discovr/verbose"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// TLS record and handshake message types, see RFC 8446 section 5.1 and 4
+const (
+	tlsRecordHandshake       = 22
+	tlsClientHello           = 1
+	tlsServerHello           = 2
+	tlsCertificate           = 11
+	tlsServerHelloDone       = 14
+	tlsVersion13             = 0x0304
+	tlsMaxStreamBuffer       = 64 * 1024
+	tlsMaxStreams            = 4096
+	tlsStreamIdle            = 30 * time.Second // streams without a packet for this long are dropped once the table is full
+	tlsCertExpiryWarningDays = 30
+)
+
+// TLS extension types used by the JA3 and JA4 fingerprints
+const (
+	tlsExtServerName        = 0x0000
+	tlsExtSupportedGroups   = 0x000a
+	tlsExtECPointFormats    = 0x000b
+	tlsExtSignatureAlgs     = 0x000d
+	tlsExtALPN              = 0x0010
+	tlsExtSupportedVersions = 0x002b
+)
+
+// tlsDetails holds what was learned from TLS handshakes, keyed by IP address.
+// Unlike hostDetails this is not keyed by MAC, since most TLS peers are remote
+// hosts behind a router.
+var (
+	tlsDetails = make(map[string]*tlsDetail)
+	tlsStreams = make(map[string]*tlsStream)
+	// tlsStreamsFull is set once the stream table has been full, so the
+	// warning is only logged once per run
+	tlsStreamsFull bool
+	// tlsTruncated is set once a handshake has been lost to the snap length,
+	// so that warning is also only logged once per run
+	tlsTruncated bool
+)
+
+type tlsDetail struct {
+	sni         map[string]struct{}
+	ja3         map[string]struct{}
+	ja4         map[string]struct{}
+	certSubject string
+	certIssuer  string
+	certSANs    string
+	certExpiry  time.Time
+}
+
+// tlsStream buffers one direction of a TCP connection until the handshake
+// messages of interest are complete. Segments must arrive in order; a gap
+// drops the stream.
+type tlsStream struct {
+	buf      []byte
+	nextSeq  uint32
+	lastSeen time.Time // capture time of the latest segment
+}
+
+func tlsDetailFor(ip string) *tlsDetail {
+	d, ok := tlsDetails[ip]
+	if !ok {
+		d = &tlsDetail{
+			sni: make(map[string]struct{}),
+			ja3: make(map[string]struct{}),
+			ja4: make(map[string]struct{}),
+		}
+		tlsDetails[ip] = d
+	}
+	return d
+}
+
+// columns returns the result columns filled from this detail record.
+func (d *tlsDetail) columns() map[string]string {
+	columns := map[string]string{
+		"SNI":         joinSet(d.sni),
+		"JA3":         joinSet(d.ja3),
+		"JA4":         joinSet(d.ja4),
+		"CertSubject": d.certSubject,
+		"CertIssuer":  d.certIssuer,
+		"CertSANs":    d.certSANs,
+	}
+	if !d.certExpiry.IsZero() {
+		columns["CertExpiry"] = d.certExpiry.Format(time.RFC3339)
+	}
+	return columns
+}
+
+// decodeTLS follows TLS handshakes in TCP traffic. ClientHellos give the SNI
+// and JA3/JA4 fingerprints of the client; the Certificate message, which is
+// only in clear text up to TLS 1.2, gives the server certificate.
+func decodeTLS(packet gopacket.Packet) {
+	tcpLayer := packet.Layer(layers.LayerTypeTCP)
+	if tcpLayer == nil {
+		return
+	}
+	tcp := tcpLayer.(*layers.TCP)
+	var srcIP, dstIP net.IP
+	if ipLayer := packet.Layer(layers.LayerTypeIPv4); ipLayer != nil {
+		ip := ipLayer.(*layers.IPv4)
+		srcIP, dstIP = ip.SrcIP, ip.DstIP
+	} else if ipLayer := packet.Layer(layers.LayerTypeIPv6); ipLayer != nil {
+		ip := ipLayer.(*layers.IPv6)
+		srcIP, dstIP = ip.SrcIP, ip.DstIP
+	} else {
+		return
+	}
+
+	key := fmt.Sprintf("%s:%d>%s:%d", srcIP, tcp.SrcPort, dstIP, tcp.DstPort)
+	stream, ok := tlsStreams[key]
+	md := packet.Metadata()
+	truncated := md != nil && md.CaptureLength < md.Length
+	if truncated && !tlsTruncated && (ok || isTLSHandshakeStart(tcp.Payload)) {
+		tlsTruncated = true
+		verbose.Printf("TLS handshake truncated to %d bytes by the capture snap length, capture with --snaplen %d to decode handshakes\n", md.CaptureLength, tlsSnapLen)
+	}
+	if tcp.FIN || tcp.RST || truncated {
+		delete(tlsStreams, key)
+		return
+	}
+	payload := tcp.Payload
+	if len(payload) == 0 {
+		return
+	}
+
+	now := packetTime(packet)
+	if !ok {
+		if !isTLSHandshakeStart(payload) {
+			return
+		}
+		if len(tlsStreams) >= tlsMaxStreams {
+			evictTLSStreams(now)
+		}
+		stream = &tlsStream{nextSeq: tcp.Seq}
+		tlsStreams[key] = stream
+	}
+	stream.lastSeen = now
+	if tcp.Seq != stream.nextSeq || len(stream.buf)+len(payload) > tlsMaxStreamBuffer {
+		delete(tlsStreams, key)
+		return
+	}
+	stream.buf = append(stream.buf, payload...)
+	stream.nextSeq = tcp.Seq + uint32(len(payload))
+
+	if parseTLSHandshake(stream.buf, srcIP.String()) {
+		delete(tlsStreams, key)
+	}
+}
+
+// evictTLSStreams makes room in the full stream table. Half-open and stalled
+// handshakes never see a FIN, so streams idle for tlsStreamIdle are dropped,
+// or else the least recently seen one.
+func evictTLSStreams(now time.Time) {
+	if !tlsStreamsFull {
+		tlsStreamsFull = true
+		verbose.Printf("TLS stream table full (%d handshakes in progress), dropping the stalest\n", tlsMaxStreams)
+	}
+	var oldestKey string
+	var oldest time.Time
+	for key, stream := range tlsStreams {
+		if now.Sub(stream.lastSeen) > tlsStreamIdle {
+			delete(tlsStreams, key)
+			continue
+		}
+		if oldestKey == "" || stream.lastSeen.Before(oldest) {
+			oldestKey, oldest = key, stream.lastSeen
+		}
+	}
+	if len(tlsStreams) >= tlsMaxStreams {
+		delete(tlsStreams, oldestKey)
+	}
+}
+
+// isTLSHandshakeStart reports whether a TCP payload begins with a TLS record
+// carrying a ClientHello or ServerHello.
+func isTLSHandshakeStart(payload []byte) bool {
+	return len(payload) > 5 && payload[0] == tlsRecordHandshake && payload[1] == 0x03 &&
+		(payload[5] == tlsClientHello || payload[5] == tlsServerHello)
+}
+
+// parseTLSHandshake walks the handshake messages buffered so far and returns
+// true once nothing more can be learned from the stream.
+func parseTLSHandshake(buf []byte, srcIP string) bool {
+	// Handshake messages may be fragmented across records, join them first
+	var hs []byte
+	complete := false
+	for len(buf) >= 5 {
+		length := int(binary.BigEndian.Uint16(buf[3:5]))
+		if buf[0] != tlsRecordHandshake {
+			complete = true
+			break
+		}
+		if len(buf) < 5+length {
+			break
+		}
+		hs = append(hs, buf[5:5+length]...)
+		buf = buf[5+length:]
+	}
+
+	for len(hs) >= 4 {
+		msgType := hs[0]
+		length := int(hs[1])<<16 | int(hs[2])<<8 | int(hs[3])
+		if len(hs) < 4+length {
+			return complete
+		}
+		body := hs[4 : 4+length]
+		hs = hs[4+length:]
+
+		switch msgType {
+		case tlsClientHello:
+			parseClientHello(body, srcIP)
+			return true
+		case tlsServerHello:
+			if serverHelloVersion(body) == tlsVersion13 {
+				// the certificate is encrypted from here on
+				return true
+			}
+		case tlsCertificate:
+			parseCertificate(body, srcIP)
+			return true
+		case tlsServerHelloDone:
+			return true
+		}
+	}
+	return complete
+}
+
+// clientHello holds the ClientHello fields the fingerprints are built from.
+type clientHello struct {
+	version    uint16
+	ciphers    []uint16
+	extensions []uint16
+	groups     []uint16
+	pointFmts  []uint16
+	sigAlgs    []uint16
+	versions   []uint16
+	serverName string
+	alpn       string
+}
+
+func parseClientHello(body []byte, srcIP string) {
+	hello, ok := readClientHello(body)
+	if !ok {
+		return
+	}
+	d := tlsDetailFor(srcIP)
+	if hello.serverName != "" {
+		d.sni[hello.serverName] = struct{}{}
+	}
+	ja3, ja4 := ja3Hash(hello), ja4Fingerprint(hello)
+	d.ja3[ja3] = struct{}{}
+	d.ja4[ja4] = struct{}{}
+	verbose.VerbosePrintf("TLS ClientHello from %s: sni=%q ja3=%s ja4=%s\n", srcIP, hello.serverName, ja3, ja4)
+}
+
+// readClientHello decodes a ClientHello body (RFC 8446 section 4.1.2).
+func readClientHello(body []byte) (clientHello, bool) {
+	var hello clientHello
+	r := tlsReader(body)
+	var sessionID, ciphers, compression, extensions tlsReader
+	if !r.readUint16(&hello.version) || !r.skip(32) || !r.readVector8(&sessionID) ||
+		!r.readVector16(&ciphers) || !r.readVector8(&compression) {
+		return hello, false
+	}
+	hello.ciphers = ciphers.uint16s()
+	if len(r) == 0 {
+		return hello, true
+	}
+	if !r.readVector16(&extensions) {
+		return hello, false
+	}
+
+	for len(extensions) > 0 {
+		var extType uint16
+		var data tlsReader
+		if !extensions.readUint16(&extType) || !extensions.readVector16(&data) {
+			return hello, false
+		}
+		hello.extensions = append(hello.extensions, extType)
+		switch extType {
+		case tlsExtServerName:
+			var list, name tlsReader
+			var nameType uint8
+			if data.readVector16(&list) && list.readUint8(&nameType) && nameType == 0 && list.readVector16(&name) {
+				hello.serverName = string(name)
+			}
+		case tlsExtSupportedGroups:
+			var list tlsReader
+			if data.readVector16(&list) {
+				hello.groups = list.uint16s()
+			}
+		case tlsExtECPointFormats:
+			var list tlsReader
+			if data.readVector8(&list) {
+				for _, f := range list {
+					hello.pointFmts = append(hello.pointFmts, uint16(f))
+				}
+			}
+		case tlsExtSignatureAlgs:
+			var list tlsReader
+			if data.readVector16(&list) {
+				hello.sigAlgs = list.uint16s()
+			}
+		case tlsExtALPN:
+			var list, proto tlsReader
+			if data.readVector16(&list) && list.readVector8(&proto) {
+				hello.alpn = string(proto)
+			}
+		case tlsExtSupportedVersions:
+			var list tlsReader
+			if data.readVector8(&list) {
+				hello.versions = list.uint16s()
+			}
+		}
+	}
+	return hello, true
+}
+
+// ja3Hash is the MD5 of SSLVersion,Ciphers,Extensions,EllipticCurves,
+// EllipticCurvePointFormats with GREASE values removed.
+func ja3Hash(hello clientHello) string {
+	fields := []string{
+		strconv.Itoa(int(hello.version)),
+		joinDecimal(hello.ciphers),
+		joinDecimal(hello.extensions),
+		joinDecimal(hello.groups),
+		joinDecimal(hello.pointFmts),
+	}
+	sum := md5.Sum([]byte(strings.Join(fields, ",")))
+	return hex.EncodeToString(sum[:])
+}
+
+// ja4Fingerprint builds the JA4 TLS client fingerprint, e.g.
+// t13d1516h2_8daaf6152771_e5627efa2ab1.
+func ja4Fingerprint(hello clientHello) string {
+	version := hello.version
+	for _, v := range withoutGREASE(hello.versions) {
+		if v > version {
+			version = v
+		}
+	}
+	sniFlag := "i"
+	if hello.serverName != "" {
+		sniFlag = "d"
+	}
+	ciphers := withoutGREASE(hello.ciphers)
+	extensions := withoutGREASE(hello.extensions)
+
+	a := fmt.Sprintf("t%s%s%02d%02d%s", ja4Version(version), sniFlag,
+		min(len(ciphers), 99), min(len(extensions), 99), ja4ALPN(hello.alpn))
+
+	var sortedExt []uint16
+	for _, e := range extensions {
+		if e != tlsExtServerName && e != tlsExtALPN {
+			sortedExt = append(sortedExt, e)
+		}
+	}
+	c := joinHex(sortedUint16(sortedExt))
+	if sigAlgs := withoutGREASE(hello.sigAlgs); len(sigAlgs) > 0 {
+		c += "_" + joinHex(sigAlgs)
+	}
+	if len(sortedExt) == 0 {
+		c = ""
+	}
+	return a + "_" + ja4Hash(joinHex(sortedUint16(ciphers))) + "_" + ja4Hash(c)
+}
+
+func ja4Version(version uint16) string {
+	switch version {
+	case 0x0304:
+		return "13"
+	case 0x0303:
+		return "12"
+	case 0x0302:
+		return "11"
+	case 0x0301:
+		return "10"
+	case 0x0300:
+		return "s3"
+	case 0x0002:
+		return "s2"
+	}
+	return "00"
+}
+
+// ja4ALPN returns the first and last character of the first ALPN value, or
+// the outer hex digits when those are not alphanumeric.
+func ja4ALPN(alpn string) string {
+	if alpn == "" {
+		return "00"
+	}
+	first, last := alpn[0], alpn[len(alpn)-1]
+	if isAlphanumeric(first) && isAlphanumeric(last) {
+		return string([]byte{first, last})
+	}
+	h := hex.EncodeToString([]byte{first, last})
+	return h[:1] + h[3:]
+}
+
+func isAlphanumeric(b byte) bool {
+	return (b >= '0' && b <= '9') || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
+
+// ja4Hash is the first 12 hex characters of the SHA-256 of s, or zeros when
+// the list it was built from is empty.
+func ja4Hash(s string) string {
+	if s == "" {
+		return "000000000000"
+	}
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// serverHelloVersion returns the negotiated version, taking the
+// supported_versions extension into account for TLS 1.3.
+func serverHelloVersion(body []byte) uint16 {
+	r := tlsReader(body)
+	var version uint16
+	var sessionID, extensions tlsReader
+	if !r.readUint16(&version) || !r.skip(32) || !r.readVector8(&sessionID) || !r.skip(3) {
+		return version
+	}
+	if !r.readVector16(&extensions) {
+		return version
+	}
+	for len(extensions) > 0 {
+		var extType, selected uint16
+		var data tlsReader
+		if !extensions.readUint16(&extType) || !extensions.readVector16(&data) {
+			break
+		}
+		if extType == tlsExtSupportedVersions && data.readUint16(&selected) {
+			return selected
+		}
+	}
+	return version
+}
+
+// parseCertificate reads the leaf certificate of a TLS 1.2 Certificate
+// message. When a server presents several certificates, for example one per
+// SNI name, the one expiring first is kept.
+func parseCertificate(body []byte, srcIP string) {
+	r := tlsReader(body)
+	var list, der tlsReader
+	if !r.readVector24(&list) || !list.readVector24(&der) {
+		return
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		verbose.VerbosePrintf("Unable to parse TLS certificate from %s: %v\n", srcIP, err)
+		return
+	}
+
+	d := tlsDetailFor(srcIP)
+	if !d.certExpiry.IsZero() && !cert.NotAfter.Before(d.certExpiry) {
+		return
+	}
+	sans := make(map[string]struct{})
+	for _, name := range cert.DNSNames {
+		sans[name] = struct{}{}
+	}
+	for _, ip := range cert.IPAddresses {
+		sans[ip.String()] = struct{}{}
+	}
+	d.certSubject = distinguishedName(cert.Subject.CommonName, cert.Subject.String())
+	d.certIssuer = distinguishedName(cert.Issuer.CommonName, cert.Issuer.String())
+	d.certSANs = joinSet(sans)
+	d.certExpiry = cert.NotAfter
+
+	verbose.VerbosePrintf("TLS certificate from %s: subject=%q issuer=%q expires=%s\n",
+		srcIP, d.certSubject, d.certIssuer, d.certExpiry.Format(time.RFC3339))
+	if time.Until(cert.NotAfter) < tlsCertExpiryWarningDays*24*time.Hour {
+		verbose.Printf("Warning: certificate %q presented by %s expires %s\n",
+			d.certSubject, srcIP, cert.NotAfter.Format(time.RFC3339))
+	}
+}
+
+func distinguishedName(commonName string, full string) string {
+	if commonName != "" {
+		return commonName
+	}
+	return full
+}
+
+// applyTLSDetails copies the decoded TLS details into the passive results.
+func applyTLSDetails() {
+	for i := range Passive_results {
+		if d, ok := tlsDetails[Passive_results[i].SrcIP]; ok {
+			setColumns(&Passive_results[i], d.columns())
+		}
+	}
+	for i := range PassiveHost_results {
+		if d, ok := tlsDetails[PassiveHost_results[i].IP]; ok {
+			setColumns(&PassiveHost_results[i], d.columns())
+		}
+	}
+}
+
+// tlsReader consumes the length-prefixed vectors TLS messages are built from.
+type tlsReader []byte
+
+func (r *tlsReader) skip(n int) bool {
+	if len(*r) < n {
+		return false
+	}
+	*r = (*r)[n:]
+	return true
+}
+
+func (r *tlsReader) readUint8(v *uint8) bool {
+	if len(*r) < 1 {
+		return false
+	}
+	*v = (*r)[0]
+	*r = (*r)[1:]
+	return true
+}
+
+func (r *tlsReader) readUint16(v *uint16) bool {
+	if len(*r) < 2 {
+		return false
+	}
+	*v = binary.BigEndian.Uint16(*r)
+	*r = (*r)[2:]
+	return true
+}
+
+func (r *tlsReader) readVector(lengthSize int, out *tlsReader) bool {
+	if len(*r) < lengthSize {
+		return false
+	}
+	length := 0
+	for _, b := range (*r)[:lengthSize] {
+		length = length<<8 | int(b)
+	}
+	if len(*r) < lengthSize+length {
+		return false
+	}
+	*out = (*r)[lengthSize : lengthSize+length]
+	*r = (*r)[lengthSize+length:]
+	return true
+}
+
+func (r *tlsReader) readVector8(out *tlsReader) bool  { return r.readVector(1, out) }
+func (r *tlsReader) readVector16(out *tlsReader) bool { return r.readVector(2, out) }
+func (r *tlsReader) readVector24(out *tlsReader) bool { return r.readVector(3, out) }
+
+func (r tlsReader) uint16s() []uint16 {
+	var values []uint16
+	for i := 0; i+1 < len(r); i += 2 {
+		values = append(values, binary.BigEndian.Uint16(r[i:]))
+	}
+	return values
+}
+
+// isGREASE reports whether v is one of the reserved values of RFC 8701 that
+// clients insert at random and fingerprints must ignore.
+func isGREASE(v uint16) bool {
+	return v&0x0f0f == 0x0a0a && v>>8 == v&0xff
+}
+
+func withoutGREASE(values []uint16) []uint16 {
+	var out []uint16
+	for _, v := range values {
+		if !isGREASE(v) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func sortedUint16(values []uint16) []uint16 {
+	sorted := append([]uint16(nil), values...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted
+}
+
+func joinDecimal(values []uint16) string {
+	var parts []string
+	for _, v := range withoutGREASE(values) {
+		parts = append(parts, strconv.Itoa(int(v)))
+	}
+	return strings.Join(parts, "-")
+}
+
+func joinHex(values []uint16) string {
+	var parts []string
+	for _, v := range values {
+		parts = append(parts, fmt.Sprintf("%04x", v))
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,156 @@
+package internal
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+func TestJA3Hash(t *testing.T) {
+	// Example from the JA3 README
+	hello := clientHello{
+		version:    769,
+		ciphers:    []uint16{47, 53, 5, 10, 49161, 49162, 49171, 49172, 50, 56, 19, 4},
+		extensions: []uint16{0, 10, 11},
+		groups:     []uint16{23, 24, 25},
+		pointFmts:  []uint16{0},
+	}
+	if got, want := ja3Hash(hello), "ada70206e40642a3e4461f35503241d5"; got != want {
+		t.Errorf("ja3Hash = %s, want %s", got, want)
+	}
+}
+
+func TestJA4Fingerprint(t *testing.T) {
+	// Chrome ClientHello from the JA4 technical details, with GREASE values
+	chrome := clientHello{
+		version: 0x0303,
+		ciphers: []uint16{0x0a0a, 0x1301, 0x1302, 0x1303, 0xc02b, 0xc02f, 0xc02c, 0xc030,
+			0xcca9, 0xcca8, 0xc013, 0xc014, 0x009c, 0x009d, 0x002f, 0x0035},
+		extensions: []uint16{0x1a1a, 0x0000, 0x0017, 0xff01, 0x000a, 0x000b, 0x0023, 0x0010,
+			0x0005, 0x000d, 0x0012, 0x0033, 0x002d, 0x002b, 0x001b, 0x0015, 0x4469},
+		sigAlgs:    []uint16{0x0403, 0x0804, 0x0401, 0x0503, 0x0805, 0x0501, 0x0806, 0x0601},
+		versions:   []uint16{0x2a2a, 0x0304, 0x0303},
+		serverName: "example.com",
+		alpn:       "h2",
+	}
+	noSNI := chrome
+	noSNI.serverName = ""
+	noSNI.alpn = ""
+	noSNI.versions = nil
+
+	tests := []struct {
+		name  string
+		hello clientHello
+		want  string
+	}{
+		{"chrome", chrome, "t13d1516h2_8daaf6152771_e5627efa2ab1"},
+		{"no sni, alpn or supported_versions", noSNI, "t12i151600_8daaf6152771_e5627efa2ab1"},
+		{"empty", clientHello{version: 0x0301}, "t10i000000_000000000000_000000000000"},
+	}
+	for _, tt := range tests {
+		if got := ja4Fingerprint(tt.hello); got != tt.want {
+			t.Errorf("%s: ja4Fingerprint = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestJA4ALPN(t *testing.T) {
+	tests := []struct {
+		alpn string
+		want string
+	}{
+		{"", "00"},
+		{"h2", "h2"},
+		{"http/1.1", "h1"},
+		{"h", "hh"},
+		{"\xab\xcd", "ad"},
+	}
+	for _, tt := range tests {
+		if got := ja4ALPN(tt.alpn); got != tt.want {
+			t.Errorf("ja4ALPN(%q) = %q, want %q", tt.alpn, got, tt.want)
+		}
+	}
+}
+
+func TestEvictTLSStreams(t *testing.T) {
+	saved, savedFull := tlsStreams, tlsStreamsFull
+	t.Cleanup(func() { tlsStreams, tlsStreamsFull = saved, savedFull })
+
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	fill := func(lastSeen func(i int) time.Time) {
+		tlsStreams = make(map[string]*tlsStream)
+		for i := range tlsMaxStreams {
+			tlsStreams[fmt.Sprint(i)] = &tlsStream{lastSeen: lastSeen(i)}
+		}
+	}
+
+	// Idle streams are all dropped
+	fill(func(i int) time.Time {
+		if i%2 == 0 {
+			return now.Add(-time.Minute)
+		}
+		return now
+	})
+	evictTLSStreams(now)
+	if got := len(tlsStreams); got != tlsMaxStreams/2 {
+		t.Errorf("after evicting idle streams %d remain, want %d", got, tlsMaxStreams/2)
+	}
+	if !tlsStreamsFull {
+		t.Error("a full table was not reported")
+	}
+
+	// Without idle streams the least recently seen one makes room
+	fill(func(i int) time.Time { return now.Add(time.Duration(i-tlsMaxStreams) * time.Millisecond) })
+	evictTLSStreams(now)
+	if got := len(tlsStreams); got != tlsMaxStreams-1 {
+		t.Errorf("after evicting the oldest stream %d remain, want %d", got, tlsMaxStreams-1)
+	}
+	if _, ok := tlsStreams["0"]; ok {
+		t.Error("the least recently seen stream was kept")
+	}
+}
+
+func TestDecodeTLSTruncated(t *testing.T) {
+	saved, savedTruncated := tlsStreams, tlsTruncated
+	t.Cleanup(func() { tlsStreams, tlsTruncated = saved, savedTruncated })
+
+	// The start of a ClientHello record that continues in later segments
+	hello := append([]byte{tlsRecordHandshake, 0x03, 0x01, 0x04, 0x00, tlsClientHello}, make([]byte, 512)...)
+	segment := func(payload []byte, captured int) gopacket.Packet {
+		packet := buildPacket(t,
+			&layers.Ethernet{SrcMAC: net.HardwareAddr{0x00, 0x50, 0x56, 0x00, 0x00, 0x01}, DstMAC: broadcastMAC, EthernetType: layers.EthernetTypeIPv4},
+			&layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolTCP, SrcIP: net.ParseIP("192.0.2.10"), DstIP: net.ParseIP("198.51.100.1")},
+			&layers.TCP{SrcPort: 40000, DstPort: 443, Seq: 1, ACK: true, PSH: true},
+			gopacket.Payload(payload),
+		)
+		if captured > 0 {
+			packet.Metadata().CaptureLength = captured
+		}
+		return packet
+	}
+
+	tests := []struct {
+		name   string
+		packet gopacket.Packet
+		warned bool
+		stream bool
+	}{
+		{"whole handshake", segment(hello, 0), false, true},
+		{"truncated handshake", segment(hello, 200), true, false},
+		{"truncated other traffic", segment(make([]byte, 512), 200), false, false},
+	}
+	for _, tt := range tests {
+		tlsStreams, tlsTruncated = make(map[string]*tlsStream), false
+		decodeTLS(tt.packet)
+		if tlsTruncated != tt.warned {
+			t.Errorf("%s: warned %v, want %v", tt.name, tlsTruncated, tt.warned)
+		}
+		if got := len(tlsStreams) == 1; got != tt.stream {
+			t.Errorf("%s: stream kept %v, want %v", tt.name, got, tt.stream)
+		}
+	}
+}
@@ -0,0 +1,321 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Naman1997/discovr/verbose"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+const (
+	trackerMaxHosts = 65536
+	trackerMaxFlows = 65536
+	trackerIdle     = 10 * time.Minute // entries without a packet for this long are dropped once a table is full
+)
+
+var (
+	PassiveHost_results []ScanResultPassiveHost
+	PassiveFlow_results []ScanResultPassiveFlow
+	tracker             = newTrafficTracker()
+)
+
+// ScanResultPassiveHost is one endpoint seen on the wire in tracking mode
+type ScanResultPassiveHost struct {
+	IP          string
+	MAC         string
+	Packets     int
+	Bytes       int
+	FirstSeen   string
+	LastSeen    string
+	Peers       string
+	Ports       string
+	Hostname    string
+	VendorClass string
+	DeviceGuess string
+	Services    string
+	UPnP        string
+	NetBIOSName string
+	Workgroup   string
+	SNI         string
+	JA3         string
+	JA4         string
+	CertSubject string
+	CertIssuer  string
+	CertSANs    string
+	CertExpiry  string
+	OSGuess     string
+	Distance    string
+	Interface   string
+	VLAN        string
+	Vendor      string
+}
+
+// ScanResultPassiveFlow is one conversation between two endpoints
+type ScanResultPassiveFlow struct {
+	AddrA     string
+	PortA     string
+	AddrB     string
+	PortB     string
+	Protocol  string
+	Packets   int
+	Bytes     int
+	FirstSeen string
+	LastSeen  string
+}
+
+type trackedHost struct {
+	ip        net.IP
+	mac       string
+	packets   int
+	bytes     int
+	firstSeen time.Time
+	lastSeen  time.Time
+	peers     map[string]struct{}
+	ports     map[string]struct{}
+}
+
+type trackedFlow struct {
+	result    ScanResultPassiveFlow
+	firstSeen time.Time
+	lastSeen  time.Time
+}
+
+// trafficTracker keeps counters for every endpoint and conversation seen,
+// regardless of whether the local machine is part of it. This is what makes
+// passive scans useful on SPAN and mirror ports.
+type trafficTracker struct {
+	hosts map[string]*trackedHost
+	flows map[string]*trackedFlow
+	// droppedHosts and droppedFlows count the entries evicted to keep the
+	// tables within trackerMaxHosts and trackerMaxFlows
+	droppedHosts int
+	droppedFlows int
+}
+
+func newTrafficTracker() *trafficTracker {
+	return &trafficTracker{
+		hosts: make(map[string]*trackedHost),
+		flows: make(map[string]*trackedFlow),
+	}
+}
+
+// observe records the endpoints and conversation of a single packet.
+func (t *trafficTracker) observe(packet gopacket.Packet) {
+	if packet == nil {
+		return
+	}
+	ts := packetTime(packet)
+	size := packet.Metadata().Length
+	if size == 0 {
+		size = len(packet.Data())
+	}
+
+	var srcMAC, dstMAC string
+	if ethLayer := packet.Layer(layers.LayerTypeEthernet); ethLayer != nil {
+		eth := ethLayer.(*layers.Ethernet)
+		srcMAC = eth.SrcMAC.String()
+		dstMAC = eth.DstMAC.String()
+	}
+
+	if arpLayer := packet.Layer(layers.LayerTypeARP); arpLayer != nil {
+		arp := arpLayer.(*layers.ARP)
+		t.host(net.IP(arp.SourceProtAddress), net.HardwareAddr(arp.SourceHwAddress).String(), ts, size)
+		return
+	}
+
+	var srcIP, dstIP net.IP
+	var protocol string
+	if ipLayer := packet.Layer(layers.LayerTypeIPv4); ipLayer != nil {
+		ip := ipLayer.(*layers.IPv4)
+		srcIP, dstIP, protocol = ip.SrcIP, ip.DstIP, ip.Protocol.String()
+	} else if ipLayer := packet.Layer(layers.LayerTypeIPv6); ipLayer != nil {
+		ip := ipLayer.(*layers.IPv6)
+		srcIP, dstIP, protocol = ip.SrcIP, ip.DstIP, ip.NextHeader.String()
+	} else {
+		return
+	}
+
+	var srcPort, dstPort string
+	if tcpLayer := packet.Layer(layers.LayerTypeTCP); tcpLayer != nil {
+		tcp := tcpLayer.(*layers.TCP)
+		srcPort, dstPort = fmt.Sprint(uint16(tcp.SrcPort)), fmt.Sprint(uint16(tcp.DstPort))
+	} else if udpLayer := packet.Layer(layers.LayerTypeUDP); udpLayer != nil {
+		udp := udpLayer.(*layers.UDP)
+		srcPort, dstPort = fmt.Sprint(uint16(udp.SrcPort)), fmt.Sprint(uint16(udp.DstPort))
+	}
+
+	src := t.host(srcIP, srcMAC, ts, size)
+	dst := t.host(dstIP, dstMAC, ts, size)
+	if src != nil && dst != nil {
+		src.peers[dstIP.String()] = struct{}{}
+		dst.peers[srcIP.String()] = struct{}{}
+	}
+	if src != nil && srcPort != "" {
+		src.ports[strings.ToLower(protocol)+"/"+srcPort] = struct{}{}
+	}
+	if dst != nil && dstPort != "" {
+		dst.ports[strings.ToLower(protocol)+"/"+dstPort] = struct{}{}
+	}
+
+	t.flow(srcIP, srcPort, dstIP, dstPort, protocol, ts, size)
+}
+
+// host updates the counters of a unicast endpoint, ignoring broadcast,
+// multicast and unspecified addresses which are not real hosts.
+func (t *trafficTracker) host(ip net.IP, mac string, ts time.Time, size int) *trackedHost {
+	if !isTrackableIP(ip) {
+		return nil
+	}
+	key := ip.String()
+	h, ok := t.hosts[key]
+	if !ok {
+		if len(t.hosts) >= trackerMaxHosts {
+			dropped := evictStale(t.hosts, func(h *trackedHost) time.Time { return h.lastSeen }, ts, trackerMaxHosts)
+			t.droppedHosts += dropped
+			verbose.Printf("Host table full (%d hosts), dropped %d stale hosts (%d so far)\n", trackerMaxHosts, dropped, t.droppedHosts)
+		}
+		h = &trackedHost{
+			ip:        ip,
+			firstSeen: ts,
+			peers:     make(map[string]struct{}),
+			ports:     make(map[string]struct{}),
+		}
+		t.hosts[key] = h
+	}
+	if h.mac == "" && isUnicastMAC(mac) {
+		h.mac = mac
+	}
+	h.packets++
+	h.bytes += size
+	if ts.Before(h.firstSeen) {
+		h.firstSeen = ts
+	}
+	if ts.After(h.lastSeen) {
+		h.lastSeen = ts
+	}
+	return h
+}
+
+// flow updates a conversation, keyed so both directions map to the same entry.
+func (t *trafficTracker) flow(srcIP net.IP, srcPort string, dstIP net.IP, dstPort string, protocol string, ts time.Time, size int) {
+	a, portA, b, portB := srcIP.String(), srcPort, dstIP.String(), dstPort
+	if bytes.Compare(srcIP.To16(), dstIP.To16()) > 0 {
+		a, portA, b, portB = b, portB, a, portA
+	}
+	key := strings.Join([]string{protocol, a, portA, b, portB}, "|")
+	f, ok := t.flows[key]
+	if !ok {
+		if len(t.flows) >= trackerMaxFlows {
+			dropped := evictStale(t.flows, func(f *trackedFlow) time.Time { return f.lastSeen }, ts, trackerMaxFlows)
+			t.droppedFlows += dropped
+			verbose.Printf("Flow table full (%d flows), dropped %d stale flows (%d so far)\n", trackerMaxFlows, dropped, t.droppedFlows)
+		}
+		f = &trackedFlow{
+			result:    ScanResultPassiveFlow{AddrA: a, PortA: portA, AddrB: b, PortB: portB, Protocol: protocol},
+			firstSeen: ts,
+		}
+		t.flows[key] = f
+	}
+	f.result.Packets++
+	f.result.Bytes += size
+	if ts.Before(f.firstSeen) {
+		f.firstSeen = ts
+	}
+	if ts.After(f.lastSeen) {
+		f.lastSeen = ts
+	}
+}
+
+// evictStale makes room in a full table. Entries idle for trackerIdle are
+// dropped first, then the least recently seen until the table is a tenth below
+// limit, so a busy capture does not rescan the table for every new entry. It
+// returns how many entries were dropped.
+func evictStale[V any](table map[string]V, lastSeen func(V) time.Time, now time.Time, limit int) int {
+	before := len(table)
+	keys := make([]string, 0, len(table))
+	for key, v := range table {
+		if now.Sub(lastSeen(v)) > trackerIdle {
+			delete(table, key)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	if len(table) >= limit {
+		sort.Slice(keys, func(i, j int) bool { return lastSeen(table[keys[i]]).Before(lastSeen(table[keys[j]])) })
+		keep := limit - max(limit/10, 1)
+		for _, key := range keys[:len(table)-keep] {
+			delete(table, key)
+		}
+	}
+	return before - len(table)
+}
+
+// results converts the tracked state into exportable host and flow tables,
+// hosts sorted by address and flows by traffic volume.
+func (t *trafficTracker) results() ([]ScanResultPassiveHost, []ScanResultPassiveFlow) {
+	var hosts []ScanResultPassiveHost
+	var hostIPs []net.IP
+	for _, h := range t.hosts {
+		hostIPs = append(hostIPs, h.ip)
+	}
+	sort.Slice(hostIPs, func(i, j int) bool { return bytes.Compare(hostIPs[i].To16(), hostIPs[j].To16()) < 0 })
+	for _, ip := range hostIPs {
+		h := t.hosts[ip.String()]
+		hosts = append(hosts, ScanResultPassiveHost{
+			IP:        ip.String(),
+			MAC:       h.mac,
+			Packets:   h.packets,
+			Bytes:     h.bytes,
+			FirstSeen: h.firstSeen.Format(time.RFC3339),
+			LastSeen:  h.lastSeen.Format(time.RFC3339),
+			Peers:     joinSet(h.peers),
+			Ports:     joinSet(h.ports),
+		})
+	}
+
+	var flows []ScanResultPassiveFlow
+	for _, f := range t.flows {
+		r := f.result
+		r.FirstSeen = f.firstSeen.Format(time.RFC3339)
+		r.LastSeen = f.lastSeen.Format(time.RFC3339)
+		flows = append(flows, r)
+	}
+	sort.Slice(flows, func(i, j int) bool { return flows[i].Bytes > flows[j].Bytes })
+	return hosts, flows
+}
+
+// finishTracking publishes the tracked hosts and flows as scan results.
+func finishTracking() {
+	PassiveHost_results, PassiveFlow_results = tracker.results()
+}
+
+func isTrackableIP(ip net.IP) bool {
+	if ip == nil || ip.IsUnspecified() || ip.IsMulticast() || ip.IsLoopback() {
+		return false
+	}
+	return !ip.Equal(net.IPv4bcast)
+}
+
+func isUnicastMAC(mac string) bool {
+	hw, err := net.ParseMAC(mac)
+	return err == nil && len(hw) > 0 && hw[0]&0x01 == 0
+}
+
+func joinSet(set map[string]struct{}) string {
+	return strings.Join(sortedSet(set), " ")
+}
+
+func sortedSet(set map[string]struct{}) []string {
+	var items []string
+	for item := range set {
+		items = append(items, item)
+	}
+	sort.Strings(items)
+	return items
+}
@@ -0,0 +1,69 @@
+package internal
+
+import (
+	"net"
+	"slices"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestEvictStale(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		ages  map[string]time.Duration
+		limit int
+		want  []string
+	}{
+		{"idle entries go first", map[string]time.Duration{"a": trackerIdle + time.Second, "b": time.Second, "c": 2 * time.Second}, 3, []string{"b", "c"}},
+		{"stalest when none are idle", map[string]time.Duration{"a": 3 * time.Second, "b": time.Second, "c": 2 * time.Second}, 3, []string{"b", "c"}},
+		{"a tenth below the limit", map[string]time.Duration{
+			"a": 10 * time.Second, "b": 9 * time.Second, "c": 8 * time.Second, "d": 7 * time.Second, "e": 6 * time.Second,
+			"f": 5 * time.Second, "g": 4 * time.Second, "h": 3 * time.Second, "i": 2 * time.Second, "j": time.Second,
+		}, 10, []string{"b", "c", "d", "e", "f", "g", "h", "i", "j"}},
+		{"all idle", map[string]time.Duration{"a": 2 * trackerIdle, "b": 3 * trackerIdle}, 2, nil},
+	}
+	for _, tt := range tests {
+		table := make(map[string]time.Time)
+		for key, age := range tt.ages {
+			table[key] = now.Add(-age)
+		}
+		dropped := evictStale(table, func(seen time.Time) time.Time { return seen }, now, tt.limit)
+		var got []string
+		for key := range table {
+			got = append(got, key)
+		}
+		sort.Strings(got)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: kept %v, want %v", tt.name, got, tt.want)
+		}
+		if dropped != len(tt.ages)-len(tt.want) {
+			t.Errorf("%s: dropped %d, want %d", tt.name, dropped, len(tt.ages)-len(tt.want))
+		}
+	}
+}
+
+func TestTrafficTrackerLimits(t *testing.T) {
+	tr := newTrafficTracker()
+	start := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	for i := 0; i <= trackerMaxFlows; i++ {
+		ts := start.Add(time.Duration(i) * time.Millisecond)
+		src := net.IPv4(10, byte(i>>16), byte(i>>8), byte(i))
+		tr.host(src, "", ts, 60)
+		tr.flow(src, "40000", net.IPv4(192, 0, 2, 1), "443", "TCP", ts, 60)
+	}
+	if len(tr.hosts) > trackerMaxHosts || len(tr.flows) > trackerMaxFlows {
+		t.Fatalf("tracking %d hosts and %d flows, limits are %d and %d", len(tr.hosts), len(tr.flows), trackerMaxHosts, trackerMaxFlows)
+	}
+	if tr.droppedHosts+len(tr.hosts) != trackerMaxHosts+1 || tr.droppedFlows+len(tr.flows) != trackerMaxFlows+1 {
+		t.Errorf("dropped %d hosts and %d flows, keeping %d and %d", tr.droppedHosts, tr.droppedFlows, len(tr.hosts), len(tr.flows))
+	}
+	// The newest conversation survives, the oldest was dropped
+	if _, ok := tr.flows["TCP|10.1.0.0|40000|192.0.2.1|443"]; !ok {
+		t.Error("the newest flow was evicted")
+	}
+	if _, ok := tr.hosts["10.0.0.0"]; ok {
+		t.Error("the stalest host was kept")
+	}
+}
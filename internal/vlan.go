@@ -0,0 +1,27 @@
+package internal
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// seenVLANs records the 802.1Q VLAN IDs every source IP and MAC address was
+// seen on. Stacked (QinQ) tags are written outer first, e.g. "100.20".
+var seenVLANs = make(addressTags)
+
+// observeVLAN records the VLAN tags of a frame captured on a trunk port.
+// Untagged frames carry no VLAN and are not recorded.
+func observeVLAN(packet gopacket.Packet) {
+	var ids []string
+	for _, layer := range packet.Layers() {
+		if dot1q, ok := layer.(*layers.Dot1Q); ok {
+			ids = append(ids, strconv.Itoa(int(dot1q.VLANIdentifier)))
+		}
+	}
+	if len(ids) > 0 {
+		seenVLANs.add(packet, strings.Join(ids, "."))
+	}
+}
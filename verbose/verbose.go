@@ -1,6 +1,7 @@
 package verbose
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -27,7 +28,7 @@ func Printf(format string, a ...interface{}) {
 func VerboseErrorf(format string, a ...interface{}) error {
 	msg := fmt.Sprintf(format, a...)
 	log.Println("[ERROR]", msg)
-	return fmt.Errorf(msg)
+	return errors.New(msg)
 }
 
 func VerboseFatal(err error) {